@@ -1,143 +0,0 @@
-package main
-
-var idiomsMap = map[string]string{
-	"安": "安步当车",
-	"拔": "拔苗助长",
-	"百": "百折不挠",
-	"半": "半途而废",
-	"饱": "饱经风霜",
-	"暴": "暴风骤雨",
-	"本": "本末倒置",
-	"闭": "闭门思过",
-	"表": "表里如一",
-	"别": "别具一格",
-	"冰": "冰天雪地",
-	"兵": "兵临城下",
-	"不": "不屈不挠",
-	"步": "步步高升",
-	"才": "才华横溢",
-	"财": "财源广进",
-	"惨": "惨不忍睹",
-	"苍": "苍白无力",
-	"草": "草木皆兵",
-	"差": "差强人意",
-	"长": "长治久安",
-	"车": "车水马龙",
-	"沉": "沉默寡言",
-	"趁": "趁热打铁",
-	"成": "成家立业",
-	"诚": "诚心诚意",
-	"吃": "吃苦耐劳",
-	"持": "持之以恒",
-	"赤": "赤手空拳",
-	"重": "重见天日",
-	"出": "出类拔萃",
-	"处": "处心积虑",
-	"春": "春风得意",
-	"唇": "唇枪舌剑",
-	"此": "此起彼伏",
-	"从": "从容不迫",
-	"大": "大义灭亲",
-	"代": "代代相传",
-	"当": "当务之急",
-	"道": "道听途说",
-	"得": "得心应手",
-	"等": "等闲视之",
-	"地": "地动山摇",
-	"点": "点石成金",
-	"电": "电闪雷鸣",
-	"东": "东张西望",
-	"斗": "斗志昂扬",
-	"独": "独一无二",
-	"对": "对牛弹琴",
-	"多": "多才多艺",
-	"恶": "恶贯满盈",
-	"恩": "恩重如山",
-	"发": "发扬光大",
-	"反": "反败为胜",
-	"方": "方寸不乱",
-	"放": "放任自流",
-	"飞": "飞蛾扑火",
-	"非": "非同小可",
-	"奉": "奉公守法",
-	"故": "故弄玄虚",
-	"顾": "顾此失彼",
-	"挂": "挂一漏万",
-	"关": "关门大吉",
-	"光": "光彩夺目",
-	"广": "广开言路",
-	"归": "归心似箭",
-	"国": "国泰民安",
-	"胸": "胸有成竹",
-	"竹": "竹报平安",
-	"荣": "荣华富贵",
-	"贵": "贵而贱目",
-	"目": "目无余子",
-	"子": "子虚乌有",
-	"有": "有目共睹",
-	"睹": "睹物思人",
-	"人": "人中骐骥",
-	"骥": "骥子龙文",
-	"文": "文质彬彬",
-	"彬": "彬彬有礼",
-	"礼": "礼贤下士",
-	"士": "士饱马腾",
-	"腾": "腾云驾雾",
-	"雾": "雾里看花",
-	"花": "花言巧语",
-	"语": "语重心长",
-	"往": "往返徒劳",
-	"劳": "劳而无功",
-	"功": "功成不居",
-	"居": "居官守法",
-	"法": "法外施仁",
-	"仁": "仁浆义粟",
-	"粟": "粟红贯朽",
-	"朽": "朽木死灰",
-	"灭": "灭绝人性",
-	"性": "性命交关",
-	"吉": "吉祥止止",
-	"止": "止于至善",
-	"善": "善贾而沽",
-	"沽": "沽名钓誉",
-	"誉": "誉不绝口",
-	"口": "口蜜腹剑",
-	"剑": "剑戟森森",
-	"森": "森罗万象",
-	"象": "象箸玉杯",
-	"杯": "杯弓蛇影",
-	"影": "影影绰绰",
-	"绰": "绰约多姿",
-	"姿": "姿意妄为",
-	"为": "为人作嫁",
-	"嫁": "嫁祸于人",
-	"暖": "暖衣饱食",
-	"食": "食不果腹",
-	"腹": "腹背之毛",
-	"毛": "毛手毛脚",
-	"脚": "脚踏实地",
-	"荒": "荒诞不经",
-	"经": "经纬万端",
-	"端": "端倪可察",
-	"察": "察言观色",
-	"色": "色若死灰",
-	"灰": "灰头土面",
-	"面": "面有菜色",
-	"与": "与民更始",
-	"始": "始乱终弃",
-	"弃": "弃瑕录用",
-	"用": "用舍行藏",
-	"藏": "藏垢纳污",
-	"污": "污泥浊水",
-	"水": "水乳交融",
-	"融": "融会贯通",
-	"通": "通宵达旦",
-	"旦": "旦种暮成",
-	"美": "美人迟暮",
-	"暮": "暮云春树",
-	"树": "树大招风",
-	"风": "风中之烛",
-	"烛": "烛照数计",
-	"计": "计日程功",
-	"量": "量才录用",
-}
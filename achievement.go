@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// GameEventKind 触发成就判定的游戏事件
+type GameEventKind int
+
+// 游戏事件
+const (
+	EventSeen     GameEventKind = iota // 玩家与机器人互动
+	EventAnswer                        // 答对一个成语
+	EventGameOver                      // 一局接龙结束，单人和多人接龙都算
+	EventRelayWin                      // 在多人接龙中获得第一名
+)
+
+// GameEvent 一次游戏事件及判定成就所需的数据
+type GameEvent struct {
+	Kind      GameEventKind
+	Streak    int  // 答对时为当前连击数，一局结束时为本局最长连击
+	Rare      bool // 答对的是生僻字开头的成语，与积分规则中的生僻字加成一致
+	DayStreak int  // 连续活跃的天数
+}
+
+// Achievement 一个成就：在 On 事件发生时用 Done 判定是否达成，解锁后不会再失去
+// ID 保存在数据库中，发布后不要修改；新增成就只需在 achievements 中追加一项
+type Achievement struct {
+	ID   string
+	Name string
+	Desc string
+	On   GameEventKind
+	Done func(e GameEvent) bool
+}
+
+// achievements 全部成就，我的成就按这里的顺序列出
+var achievements = []Achievement{
+	{
+		ID: "first_game", Name: "初出茅庐", Desc: "完成第一局成语接龙",
+		On: EventGameOver, Done: func(e GameEvent) bool { return true },
+	},
+	{
+		ID: "streak_10", Name: "妙语连珠", Desc: "单局连续答对10个成语",
+		On: EventAnswer, Done: func(e GameEvent) bool { return e.Streak >= 10 },
+	},
+	{
+		ID: "login_7", Name: "持之以恒", Desc: "连续7天与机器人互动",
+		On: EventSeen, Done: func(e GameEvent) bool { return e.DayStreak >= 7 },
+	},
+	{
+		ID: "rare_idiom", Name: "博古通今", Desc: "答出一个生僻字开头的成语",
+		On: EventAnswer, Done: func(e GameEvent) bool { return e.Rare },
+	},
+	{
+		ID: "relay_win", Name: "一马当先", Desc: "在多人接龙中获得第一名",
+		On: EventRelayWin, Done: func(e GameEvent) bool { return true },
+	},
+}
+
+// achievementByID 按 ID 查找成就，已下线的成就返回 nil
+func achievementByID(id string) *Achievement {
+	for i := range achievements {
+		if achievements[i].ID == id {
+			return &achievements[i]
+		}
+	}
+	return nil
+}
+
+// achieve 按事件判定玩家的成就，返回本次新解锁成就的播报，没有新解锁时为空
+func (p Processor) achieve(ctx context.Context, userID, name string, e GameEvent) string {
+	var unlocked []string
+	for _, a := range achievements {
+		if a.On != e.Kind || !a.Done(e) {
+			continue
+		}
+		ok, err := p.store.UnlockAchievement(ctx, userID, a.ID, time.Now())
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if ok {
+			unlocked = append(unlocked, fmt.Sprintf("【%s】%s", a.Name, a.Desc))
+		}
+	}
+	if len(unlocked) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n恭喜%s解锁成就：%s", name, strings.Join(unlocked, "，"))
+}
+
+// seeAchievements 玩家互动后判定连续活跃类的成就，解锁时在子频道内播报
+func (p Processor) seeAchievements(ctx context.Context, data *Message, dayStreak int) {
+	if text := p.achieve(ctx, data.Author.ID, displayName(data), GameEvent{Kind: EventSeen, DayStreak: dayStreak}); text != "" {
+		p.notify(data, strings.TrimPrefix(text, "\n"))
+	}
+}
+
+// myAchievements 消息发送者已解锁和未解锁的成就
+func (p Processor) myAchievements(ctx context.Context, data *Message) string {
+	unlocked, err := p.store.QueryAchievements(ctx, data.Author.ID)
+	if err != nil {
+		log.Println(err)
+		return "成就暂时无法查询，请稍后再试。"
+	}
+	unlockedAt := map[string]time.Time{}
+	for _, u := range unlocked {
+		if achievementByID(u.ID) != nil {
+			unlockedAt[u.ID] = u.UnlockedAt
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "你好，%s已解锁%d/%d个成就：", displayName(data), len(unlockedAt), len(achievements))
+	for _, a := range achievements {
+		if at, ok := unlockedAt[a.ID]; ok {
+			fmt.Fprintf(&b, "\n【%s】%s（%s解锁）", a.Name, a.Desc, at.Format("2006-01-02"))
+		}
+	}
+	if len(unlockedAt) < len(achievements) {
+		b.WriteString("\n未解锁：")
+		for _, a := range achievements {
+			if _, ok := unlockedAt[a.ID]; !ok {
+				fmt.Fprintf(&b, "\n【%s】%s", a.Name, a.Desc)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrSnapshotUnsupported 数据库不支持由机器人做快照，如 PostgreSQL
+var ErrSnapshotUnsupported = errors.New("snapshot is only supported for sqlite, back up postgresql with pg_dump")
+
+// 备份文件名形如 backup-20231001-043000.db.gz，按文件名中的时间判断备份的先后
+const (
+	backupPrefix     = "backup-"
+	backupSuffix     = ".db.gz"
+	backupTimeLayout = "20060102-150405"
+)
+
+// Snapshot 用 VACUUM INTO 把数据库的一致性快照写到 file，备份期间机器人可以照常读写
+func (s *Store) Snapshot(ctx context.Context, file string) error {
+	if s.dialect.name != sqliteDialect.name {
+		return ErrSnapshotUnsupported
+	}
+	if _, err := s.db.ExecContext(ctx, "VACUUM INTO ?", file); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	return nil
+}
+
+// Backup 一份备份文件
+type Backup struct {
+	File string
+	At   time.Time
+}
+
+// listBackups 备份目录中的全部备份，从新到旧排列，目录不存在时为空
+func listBackups(dir string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []Backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix)
+		at, err := time.ParseInLocation(backupTimeLayout, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{File: filepath.Join(dir, name), At: at})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].At.After(backups[j].At)
+	})
+	return backups, nil
+}
+
+// backupDatabase 给数据库做快照并压缩保存到备份目录，返回备份文件
+func backupDatabase(ctx context.Context, store Storage, dir string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, backupPrefix+now.Format(backupTimeLayout)+backupSuffix)
+	snapshot := file + ".snapshot"
+	os.Remove(snapshot) // VACUUM INTO 要求目标文件不存在，清理上次中断留下的快照
+	defer os.Remove(snapshot)
+	if err := store.Snapshot(ctx, snapshot); err != nil {
+		return "", err
+	}
+
+	// 先写到临时文件，压缩完成后再改名，中途失败不会留下不完整的备份
+	part := file + ".part"
+	if err := gzipFile(snapshot, part); err != nil {
+		os.Remove(part)
+		return "", err
+	}
+	if err := os.Rename(part, file); err != nil {
+		os.Remove(part)
+		return "", err
+	}
+	return file, nil
+}
+
+// gzipFile 把 src 压缩写入 dst
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	zw := gzip.NewWriter(out)
+	zw.Name = filepath.Base(src)
+	if _, err := io.Copy(zw, in); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// pruneBackups 按保留策略删除过期的备份：保留最近 keepDaily 天每天最新的一份，
+// 以及最近 keepWeekly 周每周最新的一份，返回删除的备份
+func pruneBackups(dir string, keepDaily, keepWeekly int) ([]Backup, error) {
+	backups, err := listBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+	days, weeks := map[string]bool{}, map[string]bool{}
+	var removed []Backup
+	for _, b := range backups {
+		keep := false
+		if day := b.At.Format("2006-01-02"); !days[day] && len(days) < keepDaily {
+			days[day] = true
+			keep = true
+		}
+		year, week := b.At.ISOWeek()
+		if key := fmt.Sprintf("%d-%02d", year, week); !weeks[key] && len(weeks) < keepWeekly {
+			weeks[key] = true
+			keep = true
+		}
+		if keep {
+			continue
+		}
+		if err := os.Remove(b.File); err != nil {
+			return removed, err
+		}
+		removed = append(removed, b)
+	}
+	return removed, nil
+}
+
+// backupAndPrune 按配置备份数据库并清理过期的备份
+func backupAndPrune(ctx context.Context, store Storage, now time.Time) (string, []Backup, error) {
+	file, err := backupDatabase(ctx, store, conf.Backup.dir(), now)
+	if err != nil {
+		return "", nil, err
+	}
+	keepDaily, keepWeekly := conf.Backup.keep()
+	removed, err := pruneBackups(conf.Backup.dir(), keepDaily, keepWeekly)
+	return file, removed, err
+}
+
+// runBackups 每天在配置的时间备份数据库，没有配置时间或使用 PostgreSQL 时直接返回
+func runBackups(store Storage) {
+	if conf.Backup.Time == "" {
+		return
+	}
+	if isPostgresDSN(conf.Database) {
+		log.Println("PostgreSQL 数据库请使用 pg_dump 等工具在数据库侧备份，跳过自动备份")
+		return
+	}
+	hour, minute, err := conf.Backup.at()
+	if err != nil {
+		log.Printf("备份时间 %q 格式错误，应为 15:04：%v", conf.Backup.Time, err)
+		return
+	}
+	for {
+		now := time.Now()
+		next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		time.Sleep(next.Sub(now))
+
+		file, removed, err := backupAndPrune(context.Background(), store, time.Now())
+		if err != nil {
+			log.Printf("数据库备份失败：%v", err)
+			continue
+		}
+		log.Printf("database backed up to %s, %d expired backups removed\n", file, len(removed))
+	}
+}
+
+// BackupInfo 校验通过的备份中的数据概况
+type BackupInfo struct {
+	Version int // 已执行到的迁移版本
+	Scores  int // 积分流水条数
+	Players int // 玩家资料份数
+}
+
+// restoreBackup 用备份替换 SQLite 数据库：先解压到数据库所在目录并校验，校验通过后
+// 把现有的数据库（连同 WAL 文件）改名为 .before-restore-时间 保留，再换上备份，需要先停止机器人
+func restoreBackup(ctx context.Context, backup, database string, now time.Time) (BackupInfo, error) {
+	restored := database + ".restore"
+	os.Remove(restored)
+	if err := unpackBackup(backup, restored); err != nil {
+		os.Remove(restored)
+		return BackupInfo{}, err
+	}
+	info, err := validateBackup(ctx, restored)
+	if err != nil {
+		os.Remove(restored)
+		return info, err
+	}
+
+	// 替换期间持有现有数据库的排他锁，机器人或其他命令打开着数据库时拒绝恢复
+	unlock, err := lockDatabase(ctx, database)
+	if err != nil {
+		os.Remove(restored)
+		return info, err
+	}
+	defer unlock()
+
+	kept := database + ".before-restore-" + now.Format(backupTimeLayout)
+	// WAL 与 SHM 文件属于现有的数据库，留在原处会被应用到换上的备份中，改名后与现有数据库一起保留
+	var moved []string
+	rollback := func() {
+		for _, suffix := range moved {
+			if err := os.Rename(kept+suffix, database+suffix); err != nil {
+				log.Printf("failed to move %s back to %s: %v", kept+suffix, database+suffix, err)
+			}
+		}
+	}
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		err := os.Rename(database+suffix, kept+suffix)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			rollback()
+			os.Remove(restored)
+			return info, err
+		}
+		moved = append(moved, suffix)
+	}
+	if err := os.Rename(restored, database); err != nil {
+		rollback()
+		os.Remove(restored)
+		return info, err
+	}
+	return info, nil
+}
+
+// lockDatabase 取得 SQLite 数据库锁文件的排他锁：机器人运行期间一直持有共享锁，即使空闲也会让这里立即失败；
+// 再开启排他事务，挡住不经过锁文件直接打开数据库的程序。返回释放锁的函数；数据库文件不存在时无需加锁
+func lockDatabase(ctx context.Context, database string) (func(), error) {
+	if _, err := os.Stat(database); errors.Is(err, os.ErrNotExist) {
+		return func() {}, nil
+	}
+	lock, err := lockFile(database, syscall.LOCK_EX)
+	if err != nil {
+		return nil, fmt.Errorf("database %s is in use, stop the bot before restoring: %w", database, err)
+	}
+	db, err := sql.Open(sqliteDialect.driver, "file:"+database+"?mode=rw&_busy_timeout=100")
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		db.Close()
+		lock.Close()
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "BEGIN EXCLUSIVE"); err != nil {
+		conn.Close()
+		db.Close()
+		lock.Close()
+		return nil, fmt.Errorf("database %s is in use, stop the bot before restoring: %w", database, err)
+	}
+	return func() {
+		conn.ExecContext(context.Background(), "ROLLBACK")
+		conn.Close()
+		db.Close()
+		lock.Close()
+	}, nil
+}
+
+// unpackBackup 把备份写到 dst，.gz 结尾的备份先解压
+func unpackBackup(backup, dst string) error {
+	in, err := os.Open(backup)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	var r io.Reader = in
+	if strings.HasSuffix(backup, ".gz") {
+		zr, err := gzip.NewReader(in)
+		if err != nil {
+			return fmt.Errorf("invalid backup %s: %w", backup, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, r); err != nil {
+		return fmt.Errorf("invalid backup %s: %w", backup, err)
+	}
+	return out.Sync()
+}
+
+// validateBackup 校验备份：数据库文件完整，迁移版本不超过本程序已知的版本，积分与玩家数据表可以读取
+func validateBackup(ctx context.Context, file string) (BackupInfo, error) {
+	var info BackupInfo
+	db, err := sql.Open(sqliteDialect.driver, "file:"+file+"?mode=ro")
+	if err != nil {
+		return info, err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result); err != nil {
+		return info, fmt.Errorf("backup is not a valid sqlite database: %w", err)
+	}
+	if result != "ok" {
+		return info, fmt.Errorf("backup failed integrity check: %s", result)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&info.Version); err != nil {
+		return info, fmt.Errorf("backup has no migration records: %w", err)
+	}
+	migrations, err := loadMigrations(sqliteDialect)
+	if err != nil {
+		return info, err
+	}
+	if latest := migrations[len(migrations)-1].Version; info.Version > latest {
+		return info, fmt.Errorf("backup is at migration %d, newer than this program (%d)", info.Version, latest)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM score_ledger").Scan(&info.Scores); err != nil {
+		return info, fmt.Errorf("backup has no score ledger: %w", err)
+	}
+	// 早期的备份还没有玩家资料表
+	var hasPlayers bool
+	if err := db.QueryRowContext(ctx, sqliteDialect.tableExists, "players").Scan(&hasPlayers); err != nil || !hasPlayers {
+		return info, err
+	}
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM players").Scan(&info.Players); err != nil {
+		return info, fmt.Errorf("backup has unreadable players: %w", err)
+	}
+	return info, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newBackupFixture 建一个有一条积分的数据库并备份，备份后再记入一条，返回数据库与备份文件
+func newBackupFixture(t *testing.T) (string, string) {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+	database := filepath.Join(dir, "bot.db")
+	store := openTestStore(t, database)
+	recordScores(t, store, ScoreRecord{UserID: "u1", GuildID: "g1", ChannelID: "c1", Name: "小明", Score: 5, CreatedAt: testNow})
+	backup, err := backupDatabase(ctx, store, filepath.Join(dir, "backups"), testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordScores(t, store, ScoreRecord{UserID: "u2", GuildID: "g1", ChannelID: "c1", Name: "小红", Score: 3, CreatedAt: testNow})
+	store.Close()
+	return database, backup
+}
+
+// countScores 数据库文件中的积分流水条数
+func countScores(t *testing.T, file string) int {
+	t.Helper()
+	store, err := OpenStorageReadOnly(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	scores, err := store.ListScores(context.Background(), "g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(scores)
+}
+
+func TestRestoreBackup(t *testing.T) {
+	database, backup := newBackupFixture(t)
+	info, err := restoreBackup(context.Background(), backup, database, testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Scores != 1 {
+		t.Errorf("restored info = %+v, want 1 score", info)
+	}
+	if n := countScores(t, database); n != 1 {
+		t.Errorf("restored database has %d scores, want 1", n)
+	}
+	kept := database + ".before-restore-" + testNow.Format(backupTimeLayout)
+	if n := countScores(t, kept); n != 2 {
+		t.Errorf("kept database has %d scores, want 2", n)
+	}
+	if _, err := os.Stat(database + ".restore"); !os.IsNotExist(err) {
+		t.Errorf("temporary restore file left behind: %v", err)
+	}
+}
+
+func TestRestoreBackupInUse(t *testing.T) {
+	ctx := context.Background()
+	database, backup := newBackupFixture(t)
+
+	// 模拟机器人正在写入
+	db, err := sql.Open(sqliteDialect.driver, "file:"+database+"?_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	_, err = restoreBackup(ctx, backup, database, testNow)
+	if err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Fatalf("restore while in use: err = %v", err)
+	}
+	tx.Rollback()
+	db.Close()
+
+	if n := countScores(t, database); n != 2 {
+		t.Errorf("database has %d scores after refused restore, want 2", n)
+	}
+	assertNoRestoreFiles(t, database)
+}
+
+// assertNoRestoreFiles 被拒绝的恢复不应在数据库旁留下文件，锁文件除外
+func assertNoRestoreFiles(t *testing.T, database string) {
+	t.Helper()
+	matches, _ := filepath.Glob(database + ".*")
+	for _, m := range matches {
+		if m != database+".lock" {
+			t.Errorf("refused restore left %s behind", m)
+		}
+	}
+}
+
+func TestRestoreBackupBotIdle(t *testing.T) {
+	ctx := context.Background()
+	database, backup := newBackupFixture(t)
+
+	// 模拟空闲的机器人：打开着数据库，但没有任何读写
+	store, err := OpenStorage(ctx, database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	_, err = restoreBackup(ctx, backup, database, testNow)
+	if err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Fatalf("restore while the bot is idle: err = %v", err)
+	}
+	assertNoRestoreFiles(t, database)
+
+	// 机器人停止后可以恢复
+	store.Close()
+	if _, err := restoreBackup(ctx, backup, database, testNow); err != nil {
+		t.Fatal(err)
+	}
+	if n := countScores(t, database); n != 1 {
+		t.Errorf("database has %d scores after restore, want 1", n)
+	}
+}
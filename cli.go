@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cliUsage 运维子命令的用法
+const cliUsage = `用法：
+  bot                        启动机器人
+  bot migrate status         查看数据库迁移的执行情况
+  bot migrate up [版本]      执行未执行的迁移，指定版本时只执行到该版本
+  bot migrate down [个数]    回滚最近执行的迁移，默认回滚 1 个
+  bot export [选项]          导出积分流水和玩家资料，默认以 CSV 格式输出积分流水到标准输出
+      --format csv|json      导出格式，CSV 每次导出一种数据，JSON 可以同时导出两种
+      --data scores|players|all  导出的数据，CSV 默认 scores，JSON 默认 all
+      --guild 频道ID         只导出该频道的积分流水
+      --out 文件             输出到文件
+  bot import [选项] 文件     导入 export 导出的文件，与已有数据合并，重复的积分流水会跳过
+      --format csv|json      文件格式，默认按扩展名判断
+      --dry-run              只校验文件并统计导入结果，不保存
+  bot backup                 立即备份 SQLite 数据库到备份目录，并清理过期的备份
+  bot restore                列出备份目录中的备份
+  bot restore 文件           校验备份并用它替换 SQLite 数据库，需要先停止机器人`
+
+// runCommand 执行运维子命令，执行完即退出，不连接 QQ 频道
+func runCommand(ctx context.Context, args []string) error {
+	c, err := LoadConfig(getConfigPath("config.yaml"))
+	if err != nil {
+		return err
+	}
+	conf = c
+
+	switch args[0] {
+	case "migrate":
+		return runMigrate(ctx, args[1:])
+	case "export":
+		return runExport(ctx, args[1:])
+	case "import":
+		return runImport(ctx, args[1:])
+	case "backup":
+		return runBackup(ctx)
+	case "restore":
+		return runRestore(ctx, args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(cliUsage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], cliUsage)
+	}
+}
+
+// runMigrate 执行 migrate 子命令
+func runMigrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate action\n%s", cliUsage)
+	}
+	n := 0
+	if len(args) > 1 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 0 {
+			return fmt.Errorf("invalid number %q\n%s", args[1], cliUsage)
+		}
+	}
+
+	store, err := OpenStorage(ctx, conf.Database)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch args[0] {
+	case "status":
+		status, err := store.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range status {
+			applied := "未执行"
+			if m.Applied {
+				applied = "已执行 " + m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-16s  %s\n", m.Version, m.Name, applied)
+		}
+		return nil
+	case "up":
+		done, err := store.MigrateUp(ctx, n)
+		for _, m := range done {
+			fmt.Printf("已执行 %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("数据库已是最新版本")
+		}
+		if err != nil {
+			return err
+		}
+		return claimLegacyScores(ctx, store)
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		done, err := store.MigrateDown(ctx, n)
+		for _, m := range done {
+			fmt.Printf("已回滚 %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("没有可以回滚的迁移")
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown migrate action %q\n%s", args[0], cliUsage)
+	}
+}
+
+// runExport 执行 export 子命令
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "csv", "csv 或 json")
+	data := fs.String("data", "", "scores、players 或 all")
+	guildID := fs.String("guild", "", "只导出该频道的积分流水")
+	out := fs.String("out", "", "输出文件，默认为标准输出")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *data == "" {
+		*data = "scores"
+		if *format == "json" {
+			*data = "all"
+		}
+	}
+	withScores := *data == "scores" || *data == "all"
+	withPlayers := *data == "players" || *data == "all"
+	switch {
+	case *format != "csv" && *format != "json":
+		return fmt.Errorf("unknown format %q\n%s", *format, cliUsage)
+	case !withScores && !withPlayers:
+		return fmt.Errorf("unknown data %q\n%s", *data, cliUsage)
+	case *format == "csv" && *data == "all":
+		return fmt.Errorf("csv exports one kind of data at a time, use --data scores or --data players")
+	}
+
+	store, err := OpenStorage(ctx, conf.Database)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	file := ExportFile{Version: exportVersion, ExportedAt: time.Now()}
+	if withScores {
+		if file.Scores, err = store.ListScores(ctx, *guildID); err != nil {
+			return err
+		}
+	}
+	if withPlayers {
+		if file.Players, err = store.ListPlayers(ctx); err != nil {
+			return err
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	switch {
+	case *format == "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(file)
+	case withScores:
+		err = writeScoresCSV(w, file.Scores)
+	default:
+		err = writePlayersCSV(w, file.Players)
+	}
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		fmt.Printf("已导出 %d 条积分流水、%d 份玩家资料到 %s\n", len(file.Scores), len(file.Players), *out)
+	}
+	return nil
+}
+
+// runImport 执行 import 子命令，文件中有校验不通过的记录时整个文件都不导入
+func runImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "csv 或 json，默认按扩展名判断")
+	dryRun := fs.Bool("dry-run", false, "只校验并统计，不保存")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("missing import file\n%s", cliUsage)
+	}
+	name := fs.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	}
+	read := readCSV
+	switch *format {
+	case "csv":
+	case "json":
+		read = readJSON
+	default:
+		return fmt.Errorf("unknown format %q, use --format csv|json", *format)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scores, players, err := read(f)
+	var invalid ImportErrors
+	if errors.As(err, &invalid) {
+		for _, e := range invalid {
+			fmt.Println(e)
+		}
+		if !*dryRun {
+			return fmt.Errorf("%d invalid rows, nothing imported", len(invalid))
+		}
+	} else if err != nil {
+		return err
+	}
+
+	// --dry-run 时以只读方式打开数据库，也不执行迁移，不会改动数据库
+	open := OpenStorage
+	if *dryRun {
+		open = OpenStorageReadOnly
+	}
+	store, err := open(ctx, conf.Database)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if !*dryRun {
+		if _, err := store.MigrateUp(ctx, 0); err != nil {
+			return err
+		}
+	}
+	result, err := store.Import(ctx, scores, players, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "已导入"
+	if *dryRun {
+		verb = "校验完成，将导入"
+	}
+	fmt.Printf("%s %d 条积分流水（跳过重复 %d 条），新增 %d 份玩家资料，合并 %d 份玩家资料\n",
+		verb, result.ScoresAdded, result.ScoresSkipped, result.PlayersAdded, result.PlayersMerged)
+	if *dryRun && len(invalid) > 0 {
+		return fmt.Errorf("%d invalid rows, fix them before importing", len(invalid))
+	}
+	return nil
+}
+
+// runBackup 执行 backup 子命令
+func runBackup(ctx context.Context) error {
+	if isPostgresDSN(conf.Database) {
+		return ErrSnapshotUnsupported
+	}
+	store, err := OpenStorage(ctx, conf.Database)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	file, removed, err := backupAndPrune(ctx, store, time.Now())
+	if file != "" {
+		fmt.Printf("已备份到 %s\n", file)
+	}
+	for _, b := range removed {
+		fmt.Printf("已删除过期的备份 %s\n", b.File)
+	}
+	return err
+}
+
+// runRestore 执行 restore 子命令，不指定文件时列出可用的备份
+func runRestore(ctx context.Context, args []string) error {
+	if isPostgresDSN(conf.Database) {
+		return errors.New("restore is only supported for sqlite, restore postgresql with pg_restore or psql")
+	}
+	if len(args) == 0 {
+		backups, err := listBackups(conf.Backup.dir())
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			fmt.Printf("%s 中还没有备份\n", conf.Backup.dir())
+		}
+		for _, b := range backups {
+			fmt.Printf("%s  %s\n", b.At.Format("2006-01-02 15:04:05"), b.File)
+		}
+		return nil
+	}
+
+	now := time.Now()
+	database := sqliteFile(conf.Database)
+	info, err := restoreBackup(ctx, args[0], database, now)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已用 %s 替换数据库（迁移版本 %d，%d 条积分流水，%d 份玩家资料）\n", args[0], info.Version, info.Scores, info.Players)
+	kept := database + ".before-restore-" + now.Format(backupTimeLayout)
+	if _, err := os.Stat(kept); err == nil {
+		fmt.Printf("原数据库已保留为 %s\n", kept)
+	}
+	return nil
+}
+
+// claimLegacyScores 配置了 legacy_guild 时把早期版本的积分归入该频道，已归入的不会重复处理
+func claimLegacyScores(ctx context.Context, store Storage) error {
+	if conf.LegacyGuild == "" {
+		return nil
+	}
+	n, err := store.ClaimLegacyScores(ctx, conf.LegacyGuild)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		fmt.Printf("%d 条早期版本的积分已归入频道 %s\n", n, conf.LegacyGuild)
+	}
+	return nil
+}
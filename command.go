@@ -0,0 +1,345 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ArgType 指令参数类型
+type ArgType int
+
+// 支持的参数类型
+const (
+	ArgString   ArgType = iota // 任意字符串
+	ArgInt                     // 整数
+	ArgRune                    // 单个字符，如汉字
+	ArgUser                    // @用户，解析为用户ID
+	ArgChannel                 // #子频道，解析为子频道ID
+	ArgDuration                // 时长，如 60s、5m、30秒、2分钟
+)
+
+// String 参数类型的中文描述，用于生成错误提示
+func (t ArgType) String() string {
+	switch t {
+	case ArgInt:
+		return "整数"
+	case ArgRune:
+		return "单个字符"
+	case ArgUser:
+		return "@用户"
+	case ArgChannel:
+		return "#子频道"
+	case ArgDuration:
+		return "时长"
+	default:
+		return "文本"
+	}
+}
+
+// ArgSpec 单个参数的声明
+type ArgSpec struct {
+	Name     string  // 参数名，同时用于用法说明
+	Type     ArgType // 参数类型
+	Optional bool    // 是否可省略
+	Default  string  // 省略时使用的默认值，为空表示无默认值
+	Rest     bool    // 是否吞掉剩余全部参数（以空格拼接），只能用于最后一个参数
+}
+
+// CommandSpec 指令声明
+type CommandSpec struct {
+	Name string
+	Args []ArgSpec
+}
+
+// Usage 生成指令用法说明，如：提示 <汉字>
+func (s *CommandSpec) Usage() string {
+	var b strings.Builder
+	b.WriteString(s.Name)
+	for _, a := range s.Args {
+		if a.Optional {
+			fmt.Fprintf(&b, " [%s]", a.Name)
+		} else {
+			fmt.Fprintf(&b, " <%s>", a.Name)
+		}
+	}
+	return b.String()
+}
+
+// ArgError 参数解析错误，Error() 的内容可以直接回复给用户
+type ArgError struct {
+	Spec   *CommandSpec
+	Arg    *ArgSpec
+	Value  string
+	Reason string
+}
+
+func (e *ArgError) Error() string {
+	if e.Arg == nil {
+		return fmt.Sprintf("参数错误：%s\n用法：%s", e.Reason, e.Spec.Usage())
+	}
+	if e.Value == "" {
+		return fmt.Sprintf("缺少参数 <%s>（%s）\n用法：%s", e.Arg.Name, e.Arg.Type, e.Spec.Usage())
+	}
+	return fmt.Sprintf("参数 <%s> 的值 \"%s\" 不正确：%s\n用法：%s", e.Arg.Name, e.Value, e.Reason, e.Spec.Usage())
+}
+
+// Args 解析后的指令参数
+type Args struct {
+	Raw    []string
+	values map[string]interface{}
+}
+
+// Has 参数是否有值（显式给出或有默认值）
+func (a *Args) Has(name string) bool {
+	_, ok := a.values[name]
+	return ok
+}
+
+// String 获取字符串参数
+func (a *Args) String(name string) string {
+	v, _ := a.values[name].(string)
+	return v
+}
+
+// Int 获取整数参数
+func (a *Args) Int(name string) int {
+	v, _ := a.values[name].(int)
+	return v
+}
+
+// Rune 获取单字符参数
+func (a *Args) Rune(name string) rune {
+	v, _ := a.values[name].(rune)
+	return v
+}
+
+// User 获取 @用户 参数对应的用户ID
+func (a *Args) User(name string) string {
+	return a.String(name)
+}
+
+// Channel 获取 #子频道 参数对应的子频道ID
+func (a *Args) Channel(name string) string {
+	return a.String(name)
+}
+
+// Duration 获取时长参数
+func (a *Args) Duration(name string) time.Duration {
+	v, _ := a.values[name].(time.Duration)
+	return v
+}
+
+// Parse 按照声明解析参数
+func (s *CommandSpec) Parse(raw []string) (*Args, error) {
+	args := &Args{Raw: raw, values: map[string]interface{}{}}
+	i := 0
+	for idx := range s.Args {
+		spec := &s.Args[idx]
+		var value string
+		if i < len(raw) {
+			if spec.Rest {
+				value = strings.Join(raw[i:], " ")
+				i = len(raw)
+			} else {
+				value = raw[i]
+				i++
+			}
+		} else if spec.Default != "" {
+			value = spec.Default
+		} else if spec.Optional {
+			continue
+		} else {
+			return nil, &ArgError{Spec: s, Arg: spec}
+		}
+		v, err := convertArg(spec.Type, value)
+		if err != nil {
+			return nil, &ArgError{Spec: s, Arg: spec, Value: value, Reason: err.Error()}
+		}
+		args.values[spec.Name] = v
+	}
+	if i < len(raw) {
+		return nil, &ArgError{Spec: s, Reason: fmt.Sprintf("多余的参数 \"%s\"", strings.Join(raw[i:], " "))}
+	}
+	return args, nil
+}
+
+var (
+	userMentionRE    = regexp.MustCompile(`^<@!?(\d+)>$`)
+	channelMentionRE = regexp.MustCompile(`^<#(\d+)>$`)
+)
+
+// 中文时长单位
+var durationUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"小时", time.Hour},
+	{"分钟", time.Minute},
+	{"秒钟", time.Second},
+	{"秒", time.Second},
+	{"分", time.Minute},
+}
+
+func convertArg(t ArgType, value string) (interface{}, error) {
+	switch t {
+	case ArgInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("需要一个整数")
+		}
+		return n, nil
+	case ArgRune:
+		if utf8.RuneCountInString(value) != 1 {
+			return nil, fmt.Errorf("需要单个字符")
+		}
+		r, _ := utf8.DecodeRuneInString(value)
+		return r, nil
+	case ArgUser:
+		m := userMentionRE.FindStringSubmatch(value)
+		if m == nil {
+			return nil, fmt.Errorf("需要 @一个用户")
+		}
+		return m[1], nil
+	case ArgChannel:
+		m := channelMentionRE.FindStringSubmatch(value)
+		if m == nil {
+			return nil, fmt.Errorf("需要 #一个子频道")
+		}
+		return m[1], nil
+	case ArgDuration:
+		return parseDuration(value)
+	default:
+		return value, nil
+	}
+}
+
+// parseDuration 解析时长，支持 Go 格式（90s、5m）与中文单位（30秒、2分钟），纯数字按秒处理
+func parseDuration(value string) (time.Duration, error) {
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d, nil
+	}
+	num, unit := value, time.Second
+	for _, u := range durationUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			num, unit = strings.TrimSuffix(value, u.suffix), u.unit
+			break
+		}
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("需要一个正的时长，如 60秒、5分钟")
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// formatDuration 以中文展示时长，如 90秒、2分钟、1小时30分钟
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < time.Minute {
+		return fmt.Sprintf("%d秒", d/time.Second)
+	}
+	var b strings.Builder
+	if h := d / time.Hour; h > 0 {
+		fmt.Fprintf(&b, "%d小时", h)
+	}
+	if m := d % time.Hour / time.Minute; m > 0 {
+		fmt.Fprintf(&b, "%d分钟", m)
+	}
+	if s := d % time.Minute / time.Second; s > 0 {
+		fmt.Fprintf(&b, "%d秒", s)
+	}
+	return b.String()
+}
+
+// 成对的引号，支持半角与全角
+var quotePairs = map[rune]rune{
+	'"':  '"',
+	'\'': '\'',
+	'“':  '”',
+	'‘':  '’',
+	'「':  '」',
+}
+
+// SplitArgs 按空白切分参数，支持半角/全角空格、连续空格以及引号包裹的参数
+// 只有参数开头的引号才作为引号处理，参数中间的引号按普通字符保留，未闭合的引号会一直取到末尾
+func SplitArgs(input string) []string {
+	var (
+		args    []string
+		cur     strings.Builder
+		inToken bool
+		closing rune
+	)
+	for _, r := range input {
+		switch {
+		case closing != 0:
+			if r == closing {
+				closing = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case unicode.IsSpace(r):
+			if inToken {
+				args = append(args, cur.String())
+				cur.Reset()
+				inToken = false
+			}
+		default:
+			if c, ok := quotePairs[r]; ok && !inToken {
+				closing = c
+			} else {
+				cur.WriteRune(r)
+			}
+			inToken = true
+		}
+	}
+	if inToken {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+// commandSpecs 带参数的指令声明，未在此声明的指令不做参数校验
+var commandSpecs = map[string]*CommandSpec{
+	"加法运算": {Name: "加法运算", Args: []ArgSpec{
+		{Name: "算式", Type: ArgString, Rest: true},
+	}},
+	"成语接龙": {Name: "成语接龙", Args: []ArgSpec{
+		{Name: "选项", Type: ArgString, Optional: true, Rest: true},
+	}},
+	"多人接龙": {Name: "多人接龙", Args: []ArgSpec{
+		{Name: "选项", Type: ArgString, Optional: true, Rest: true},
+	}},
+	"积分榜": {Name: "积分榜", Args: []ArgSpec{
+		{Name: "范围", Type: ArgString, Default: "今日"},
+		{Name: "统计范围", Type: ArgString, Optional: true},
+	}},
+	"偏好设置": {Name: "偏好设置", Args: []ArgSpec{
+		{Name: "选项", Type: ArgString, Optional: true, Rest: true},
+	}},
+	"查询玩家": {Name: "查询玩家", Args: []ArgSpec{
+		{Name: "玩家", Type: ArgString, Optional: true, Rest: true},
+	}},
+	"导出积分榜": {Name: "导出积分榜", Args: []ArgSpec{
+		{Name: "范围", Type: ArgString, Default: "总榜"},
+	}},
+	"新赛季": {Name: "新赛季", Args: []ArgSpec{
+		{Name: "名称", Type: ArgString, Optional: true, Rest: true},
+	}},
+	"提示": {Name: "提示", Args: []ArgSpec{
+		{Name: "汉字", Type: ArgRune, Optional: true},
+	}},
+}
+
+// Bind 根据指令声明解析参数
+func (c *CMD) Bind() (*Args, error) {
+	spec, ok := commandSpecs[c.Cmd]
+	if !ok {
+		return &Args{Raw: c.Args, values: map[string]interface{}{}}, nil
+	}
+	return spec.Parse(c.Args)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"提示 人", []string{"提示", "人"}},
+		{"积分榜　 本周", []string{"积分榜", "本周"}},
+		{`新赛季 "春季 赛"`, []string{"新赛季", "春季 赛"}},
+		{"新赛季 “春季 赛”", []string{"新赛季", "春季 赛"}},
+		{"新赛季 「春季 赛」 x", []string{"新赛季", "春季 赛", "x"}},
+		{`a "" b`, []string{"a", "", "b"}},
+		{`a "未闭合 的引号`, []string{"a", "未闭合 的引号"}},
+		{`a"b c"`, []string{`a"b`, `c"`}},
+		{`it's ok`, []string{"it's", "ok"}},
+	}
+	for _, tt := range tests {
+		if got := SplitArgs(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitArgs(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestCommandSpecParse(t *testing.T) {
+	spec := &CommandSpec{Name: "测试", Args: []ArgSpec{
+		{Name: "次数", Type: ArgInt},
+		{Name: "字", Type: ArgRune, Default: "人"},
+		{Name: "时长", Type: ArgDuration, Optional: true},
+		{Name: "备注", Type: ArgString, Optional: true, Rest: true},
+	}}
+	tests := []struct {
+		raw     string
+		want    map[string]interface{}
+		wantErr string // ArgError 中出错的参数名，整体错误时为 "-"
+	}{
+		{raw: "3", want: map[string]interface{}{"次数": 3, "字": '人'}},
+		{raw: "3 天 90秒", want: map[string]interface{}{"次数": 3, "字": '天', "时长": 90 * time.Second}},
+		{raw: "3 天 2分钟 备注 很长", want: map[string]interface{}{"次数": 3, "字": '天', "时长": 2 * time.Minute, "备注": "备注 很长"}},
+		{raw: "", wantErr: "次数"},
+		{raw: "三", wantErr: "次数"},
+		{raw: "3 天地", wantErr: "字"},
+		{raw: "3 天 0秒", wantErr: "时长"},
+	}
+	for _, tt := range tests {
+		args, err := spec.Parse(SplitArgs(tt.raw))
+		if tt.wantErr != "" {
+			var argErr *ArgError
+			if !errors.As(err, &argErr) || argErr.Arg == nil || argErr.Arg.Name != tt.wantErr {
+				t.Errorf("Parse(%q) error = %v, want error on <%s>", tt.raw, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(args.values, tt.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.raw, args.values, tt.want)
+		}
+	}
+}
+
+func TestCommandSpecParseErrors(t *testing.T) {
+	spec := &CommandSpec{Name: "提示", Args: []ArgSpec{{Name: "汉字", Type: ArgRune, Optional: true}}}
+	_, err := spec.Parse([]string{"人", "多余"})
+	var argErr *ArgError
+	if !errors.As(err, &argErr) || argErr.Arg != nil {
+		t.Fatalf("Parse extra args error = %v, want ArgError without Arg", err)
+	}
+	if !strings.Contains(err.Error(), "多余的参数") || !strings.Contains(err.Error(), "用法：提示 [汉字]") {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+
+	mention := &CommandSpec{Name: "查询", Args: []ArgSpec{
+		{Name: "玩家", Type: ArgUser},
+		{Name: "子频道", Type: ArgChannel},
+	}}
+	args, err := mention.Parse([]string{"<@!123>", "<#456>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.User("玩家") != "123" || args.Channel("子频道") != "456" {
+		t.Errorf("mentions = %q %q, want 123 456", args.User("玩家"), args.Channel("子频道"))
+	}
+	if _, err := mention.Parse([]string{"123", "<#456>"}); err == nil {
+		t.Error("Parse accepted a plain id as @用户")
+	}
+}
+
+func TestBindUnknownCommand(t *testing.T) {
+	args, err := ParseCommand("未声明的指令 a b").Bind()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(args.Raw, []string{"a", "b"}) {
+		t.Errorf("Raw = %q, want [a b]", args.Raw)
+	}
+}
+
+func TestCommandSpecArgNames(t *testing.T) {
+	for name, spec := range commandSpecs {
+		for _, arg := range spec.Args {
+			if strings.ContainsAny(arg.Name, " \t") {
+				t.Errorf("%s: arg name %q contains whitespace", name, arg.Name)
+			}
+		}
+	}
+}
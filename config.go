@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 机器人的业务配置，与 appid、token 写在同一个 config.yaml 中
+type Config struct {
+	// IdiomDict 成语词库文件，支持 .json（与 chinese-xinhua 的 idiom.json 格式兼容）和 .csv，为空时使用 ./data/idiom.json，
+	// 该文件不存在时无法启动；填 builtin 使用内置的精简词库，只用于开发测试
+	IdiomDict string `yaml:"idiom_dict"`
+	// Game 成语接龙规则
+	Game GameConfig `yaml:"idiom_game"`
+	// Admins 管理员的用户ID，频道主和超级管理员不需要配置
+	Admins []string `yaml:"admins"`
+	// Database 数据库 DSN：SQLite 文件路径，或 postgres:// 开头的 PostgreSQL 连接串，为空时使用 ./userscores.db
+	Database string `yaml:"database"`
+	// LegacyGuild 早期版本的积分没有频道，升级后只计入全服总榜；早期版本只在一个频道中使用时填该频道的ID，
+	// 启动时把这些积分归入该频道，在频道的总榜中可以看到
+	LegacyGuild string `yaml:"legacy_guild"`
+	// GameStore 进行中的成语接龙与多人接龙保存在哪里：memory（默认，重启后丢失）或 database（保存在数据库中，重启后可以继续）
+	GameStore string `yaml:"game_store"`
+	// GlobalBoard 是否允许“积分榜 全服”查看机器人所在全部频道的积分榜，默认只能查看本频道
+	GlobalBoard bool `yaml:"global_board"`
+	// Markdown 机器人是否已开通原生 markdown 和消息按钮，开通后积分榜与帮助以 markdown 加按钮发送，否则使用 embed 与 ark
+	Markdown bool `yaml:"markdown"`
+	// DailyPush 每日积分榜推送
+	DailyPush DailyPushConfig `yaml:"daily_push"`
+	// Backup SQLite 数据库的定时备份
+	Backup BackupConfig `yaml:"backup"`
+}
+
+// DailyPushConfig 每日积分榜推送配置
+type DailyPushConfig struct {
+	// Time 每天推送的时间，如 16:11，为空时不推送
+	Time string `yaml:"time"`
+	// Targets 推送到哪些子频道
+	Targets []PushTarget `yaml:"targets"`
+}
+
+// PushTarget 一个推送目标，推送该子频道所属频道的积分榜
+type PushTarget struct {
+	// GuildID 频道ID，为空时根据积分记录查找子频道所属的频道
+	GuildID string `yaml:"guild_id"`
+	// ChannelID 推送到的子频道ID
+	ChannelID string `yaml:"channel_id"`
+	// ChannelOnly 是否只统计该子频道的积分
+	ChannelOnly bool `yaml:"channel_only"`
+}
+
+// at 解析推送时间，返回小时与分钟
+func (c DailyPushConfig) at() (hour, minute int, err error) {
+	return parseClock(c.Time)
+}
+
+// BackupConfig 定时备份配置，只对 SQLite 生效，PostgreSQL 请使用 pg_dump 等工具在数据库侧备份
+type BackupConfig struct {
+	// Time 每天备份的时间，如 04:30，为空时不自动备份
+	Time string `yaml:"time"`
+	// Dir 备份目录，为空时使用 ./backups
+	Dir string `yaml:"dir"`
+	// KeepDaily 保留最近几天的备份（每天最新的一份），为空时保留 7 天
+	KeepDaily int `yaml:"keep_daily"`
+	// KeepWeekly 保留最近几周的备份（每周最新的一份），为空时保留 4 周
+	KeepWeekly int `yaml:"keep_weekly"`
+}
+
+// at 解析备份时间，返回小时与分钟
+func (c BackupConfig) at() (hour, minute int, err error) {
+	return parseClock(c.Time)
+}
+
+// dir 备份目录
+func (c BackupConfig) dir() string {
+	if c.Dir == "" {
+		return "./backups"
+	}
+	return c.Dir
+}
+
+// keep 保留的每日与每周备份数
+func (c BackupConfig) keep() (daily, weekly int) {
+	daily, weekly = c.KeepDaily, c.KeepWeekly
+	if daily <= 0 {
+		daily = 7
+	}
+	if weekly <= 0 {
+		weekly = 4
+	}
+	return daily, weekly
+}
+
+// parseClock 解析 15:04 格式的时刻，返回小时与分钟
+func parseClock(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// GameConfig 成语接龙规则配置
+type GameConfig struct {
+	// AllowLongIdioms 是否接受四字以上的成语，如“百闻不如一见”
+	AllowLongIdioms bool `yaml:"allow_long_idioms"`
+	// AcceptUnknownIdioms 是否接受词库之外的答案，只检查全是汉字并且接得上，适合收录不全的自定义词库，默认拒绝
+	AcceptUnknownIdioms bool `yaml:"accept_unknown_idioms"`
+	// TurnTimeout 每回合的作答时限，如 60s，为空时使用 60 秒
+	TurnTimeout time.Duration `yaml:"turn_timeout"`
+	// RemindBefore 超时前多久提醒玩家，为空时不提醒
+	RemindBefore time.Duration `yaml:"remind_before"`
+	// HintBudget 每局可用的提示次数，每一级提示消耗一次，为空时不限
+	HintBudget int `yaml:"hint_budget"`
+	// Scoring 积分规则
+	Scoring ScoreConfig `yaml:"scoring"`
+}
+
+// conf 全局配置，main 启动时加载
+var conf = &Config{}
+
+// LoadConfig 从配置文件中读取业务配置，未填写的项保持默认值
+func LoadConfig(file string) (*Config, error) {
+	c := &Config{}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(content, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
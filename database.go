@@ -1,202 +1,666 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"math"
+	"os"
 	"strconv"
+	"time"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func CreateDatabase() *sql.DB {
-	db, err := sql.Open("sqlite3", "./userscores.db")
+// defaultDatabase 未配置数据库时使用的 SQLite 文件
+const defaultDatabase = "./userscores.db"
+
+// Store 基于 database/sql 的 Storage 实现，SQLite 与 PostgreSQL 共用，SQL 写法的差异由 dialect 处理
+// main 启动时创建一次，所有读写共用同一个连接池
+type Store struct {
+	db      *sql.DB
+	dialect dialect
+	games   *SQLGameStore
+	relays  *SQLRelayStore
+	lock    *os.File // SQLite 数据库锁文件，见 openSQLite
+}
+
+// OpenStore 按方言打开数据库，数据表由 MigrateUp 创建
+func OpenStore(ctx context.Context, d dialect, dsn string) (*Store, error) {
+	db, err := sql.Open(d.driver, dsn)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
-	defer db.Close()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open %s database: %w", d.name, err)
+	}
+	s := &Store{db: db, dialect: d}
+	s.games = NewSQLGameStore(db, d)
+	s.relays = NewSQLRelayStore(db, d)
+	return s, nil
+}
 
-	// 创建一个表来存储用户ID和用户分数
-	createTableSQL := `  
-	CREATE TABLE IF NOT EXISTS userscores (  
-		id TEXT PRIMARY KEY,  
-		score INTEGER NOT NULL  
-	);`
-	_, err = db.Exec(createTableSQL)
-	if err != nil {
-		panic(err.Error())
+// rebind 按方言改写查询的占位符
+func (s *Store) rebind(query string) string {
+	return s.dialect.rebind(query)
+}
+
+// GameStore 保存在数据库中的游戏状态
+func (s *Store) GameStore() GameStore {
+	return s.games
+}
+
+// RelayStore 保存在数据库中的多人接龙
+func (s *Store) RelayStore() RelayStore {
+	return s.relays
+}
+
+// Close 关闭数据库
+func (s *Store) Close() error {
+	err := s.db.Close()
+	if s.lock != nil {
+		s.lock.Close()
+		s.lock = nil
 	}
-	createTableSQL = `  
-		CREATE TABLE IF NOT EXISTS users (  
-			userid INTEGER PRIMARY KEY  
-		);`
-	_, err = db.Exec(createTableSQL)
+	return err
+}
+
+// ScoreRecord 一条积分流水
+type ScoreRecord struct {
+	UserID    string    `json:"user_id"`
+	GuildID   string    `json:"guild_id"`
+	ChannelID string    `json:"channel_id"`
+	Name      string    `json:"display_name"` // 记录时玩家展示用的名字
+	Score     int       `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// scoreRecordOf 消息发送者的积分流水，名字优先使用频道昵称
+func scoreRecordOf(data *Message, score int) ScoreRecord {
+	return ScoreRecord{
+		UserID:    data.Author.ID,
+		GuildID:   data.GuildID,
+		ChannelID: data.ChannelID,
+		Name:      displayName(data),
+		Score:     score,
+		CreatedAt: time.Now(),
+	}
+}
+
+// RecordScore 记一条积分流水
+func (s *Store) RecordScore(ctx context.Context, r ScoreRecord) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(
+		"INSERT INTO score_ledger (user_id, guild_id, channel_id, display_name, score, created_at) VALUES (?, ?, ?, ?, ?, ?)"),
+		r.UserID, r.GuildID, r.ChannelID, r.Name, r.Score, r.CreatedAt.Unix(),
+	)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("failed to record score: %w", err)
 	}
-	return db
+	return nil
 }
 
-func Insert(userID string, score int) error {
-	db, err := sql.Open("sqlite3", "./userscores.db")
+// RecordCappedScore 在一个事务中按上限记一条积分流水，返回实际计入的积分。
+// PostgreSQL 按玩家和频道加事务锁，SQLite 的事务开始时已取得写锁，同一玩家并发结算时不会超出上限
+func (s *Store) RecordCappedScore(ctx context.Context, r ScoreRecord, since time.Time, limit int) (int, error) {
+	var recorded int
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if limit > 0 {
+			if s.dialect.lockKey != "" {
+				if _, err := tx.ExecContext(ctx, s.rebind(s.dialect.lockKey), "score:"+r.UserID+":"+r.GuildID); err != nil {
+					return err
+				}
+			}
+			var today int
+			if err := tx.QueryRowContext(ctx, s.rebind(
+				"SELECT COALESCE(SUM(score), 0) FROM score_ledger WHERE user_id = ? AND guild_id = ? AND created_at >= ?"),
+				r.UserID, r.GuildID, since.Unix(),
+			).Scan(&today); err != nil {
+				return err
+			}
+			if left := limit - today; r.Score > left {
+				r.Score = left
+			}
+		}
+		if r.Score <= 0 {
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx, s.rebind(
+			"INSERT INTO score_ledger (user_id, guild_id, channel_id, display_name, score, created_at) VALUES (?, ?, ?, ?, ?, ?)"),
+			r.UserID, r.GuildID, r.ChannelID, r.Name, r.Score, r.CreatedAt.Unix(),
+		); err != nil {
+			return err
+		}
+		recorded = r.Score
+		return nil
+	})
 	if err != nil {
-		fmt.Println("打不开数据库！")
-		panic(err.Error())
+		return 0, fmt.Errorf("failed to record score: %w", err)
 	}
-	defer db.Close()
-	insertUserSQL := `  
-	INSERT INTO userscores (id, score) VALUES (?, ?);  
-	`
+	return recorded, nil
+}
 
-	_, errs := db.Exec(insertUserSQL, userID, score)
-	if db == nil {
-		return fmt.Errorf("database does not exist : %w", errs)
+// legacyUserPrefix 早期版本的 userscores 以用户名为键，迁移到积分流水时给用户ID加上的前缀
+const legacyUserPrefix = "legacy:"
+
+// ClaimLegacyScores 把早期版本迁移来的没有频道的积分归入频道
+func (s *Store) ClaimLegacyScores(ctx context.Context, guildID string) (int, error) {
+	res, err := s.db.ExecContext(ctx, s.rebind(
+		"UPDATE score_ledger SET guild_id = ? WHERE guild_id = '' AND user_id LIKE ?"),
+		guildID, legacyUserPrefix+"%",
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim legacy scores: %w", err)
 	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// QueryUserScore 查询玩家自 since 起在频道内的总积分，没有记录时为 0
+func (s *Store) QueryUserScore(ctx context.Context, userID, guildID string, since time.Time) (int, error) {
+	var score int
+	err := s.db.QueryRowContext(ctx, s.rebind(
+		"SELECT COALESCE(SUM(score), 0) FROM score_ledger WHERE user_id = ? AND guild_id = ? AND created_at >= ?"),
+		userID, guildID, since.Unix(),
+	).Scan(&score)
+	return score, err
+}
+
+// LeaderboardEntry 积分榜中的一名玩家
+type LeaderboardEntry struct {
+	Rank   int // 名次，积分相同的玩家名次相同
+	UserID string
+	Name   string // 玩家最近一次记录时的名字
+	Score  int
+}
 
+// QueryLeaderboard 汇总统计范围内 [since, until) 的积分流水，按总积分从高到低排名，until 为零值时不限
+func (s *Store) QueryLeaderboard(ctx context.Context, scope BoardScope, since, until time.Time) ([]LeaderboardEntry, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`
+	SELECT l.user_id, SUM(l.score) AS total,
+		(SELECT n.display_name FROM score_ledger n
+		WHERE n.user_id = l.user_id AND (?1 = '' OR n.guild_id = ?1)
+		ORDER BY n.created_at DESC, n.id DESC LIMIT 1)
+	FROM score_ledger l
+	WHERE (?1 = '' OR l.guild_id = ?1) AND (?2 = '' OR l.channel_id = ?2)
+		AND l.created_at >= ?3 AND l.created_at < ?4
+	GROUP BY l.user_id
+	HAVING SUM(l.score) > 0
+	ORDER BY total DESC, MIN(l.created_at) ASC;`), scope.GuildID, scope.ChannelID, since.Unix(), untilUnix(until))
 	if err != nil {
-		return fmt.Errorf("failed to insert user: %w", errs)
-	} else {
-		fmt.Println("插入成功！！！")
+		return nil, err
 	}
+	defer rows.Close()
 
-	return nil
+	var board []LeaderboardEntry
+	for rows.Next() {
+		var e LeaderboardEntry
+		if err := rows.Scan(&e.UserID, &e.Score, &e.Name); err != nil {
+			return nil, err
+		}
+		e.Rank = len(board) + 1
+		if n := len(board); n > 0 && board[n-1].Score == e.Score {
+			e.Rank = board[n-1].Rank
+		}
+		board = append(board, e)
+	}
+	return board, rows.Err()
+}
+
+// untilUnix 查询区间的结束时间，零值表示不限
+func untilUnix(until time.Time) int64 {
+	if until.IsZero() {
+		return math.MaxInt64
+	}
+	return until.Unix()
 }
 
-func PrintScoreTable() string {
-	db, err := sql.Open("sqlite3", "./userscores.db")
+// PersonalBest 玩家的个人最佳
+type PersonalBest struct {
+	Game    int       // 单局最高分
+	GameAt  time.Time // 单局最高分的时间
+	Day     int       // 单日最高分
+	DayDate string    // 单日最高分的日期，如 2023-10-01
+}
+
+// QueryPersonalBest 查询玩家在频道内的单局最高分与单日最高分，没有记录时为零值
+func (s *Store) QueryPersonalBest(ctx context.Context, userID, guildID string) (PersonalBest, error) {
+	var best PersonalBest
+	var gameAt int64
+	err := s.db.QueryRowContext(ctx, s.rebind(
+		"SELECT score, created_at FROM score_ledger WHERE user_id = ? AND guild_id = ? ORDER BY score DESC, created_at ASC LIMIT 1"),
+		userID, guildID,
+	).Scan(&best.Game, &gameAt)
+	if err == sql.ErrNoRows {
+		return best, nil
+	}
 	if err != nil {
-		panic(err.Error())
+		return best, err
 	}
-	defer db.Close()
-	// 查询并打印所有用户数据
-	queryAllUsersSQL := `  
-	SELECT id, score FROM userscores;  
-	`
+	best.GameAt = time.Unix(gameAt, 0)
+
+	err = s.db.QueryRowContext(ctx, s.rebind(`
+	SELECT `+s.dialect.dayOf("created_at")+` AS day, SUM(score) AS total
+	FROM score_ledger WHERE user_id = ? AND guild_id = ?
+	GROUP BY day ORDER BY total DESC, day ASC LIMIT 1;`), userID, guildID,
+	).Scan(&best.DayDate, &best.Day)
+	return best, err
+}
+
+// Season 赛季，从开始时间起到下一个赛季开始前的积分计入该赛季
+type Season struct {
+	ID        int
+	Name      string
+	StartedAt time.Time
+}
 
-	// 执行查询语句
-	rows, err := db.Query(queryAllUsersSQL)
+// QuerySeasons 按开始时间查询频道的全部赛季，还没有开启过赛季时返回空
+func (s *Store) QuerySeasons(ctx context.Context, guildID string) ([]Season, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind("SELECT id, name, started_at FROM seasons WHERE guild_id = ? ORDER BY started_at ASC, id ASC;"), guildID)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer rows.Close()
 
-	data := ""
-	// 逐行读取并打印用户信息
+	var seasons []Season
 	for rows.Next() {
-		var username string
-		var score int
-		if err := rows.Scan(&username, &score); err != nil {
-			log.Fatal(err)
+		var season Season
+		var startedAt int64
+		if err := rows.Scan(&season.ID, &season.Name, &startedAt); err != nil {
+			return nil, err
 		}
-		data += "\n" + username + "   " + strconv.Itoa(score)
+		season.StartedAt = time.Unix(startedAt, 0)
+		seasons = append(seasons, season)
 	}
-	return data
+	return seasons, rows.Err()
 }
 
-func DeleteTable() {
-	db, err := sql.Open("sqlite3", "./userscores.db")
+// StartSeason 为频道开启新赛季，之前的积分流水全部保留
+func (s *Store) StartSeason(ctx context.Context, guildID, name string, startedAt time.Time) (Season, error) {
+	season := Season{Name: name, StartedAt: startedAt}
+	err := s.db.QueryRowContext(ctx, s.rebind(
+		"INSERT INTO seasons (guild_id, name, started_at) VALUES (?, ?, ?) RETURNING id"),
+		guildID, name, startedAt.Unix(),
+	).Scan(&season.ID)
 	if err != nil {
-		log.Fatal(err)
+		return season, fmt.Errorf("failed to start season: %w", err)
+	}
+	return season, nil
+}
+
+// startOfDay 当天零点
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// GuildOfChannel 根据积分流水查找子频道所属的频道，没有记录时返回空
+func (s *Store) GuildOfChannel(ctx context.Context, channelID string) (string, error) {
+	var guildID string
+	err := s.db.QueryRowContext(ctx, s.rebind("SELECT guild_id FROM score_ledger WHERE channel_id = ? ORDER BY id DESC LIMIT 1"), channelID).Scan(&guildID)
+	if err == sql.ErrNoRows {
+		return "", nil
 	}
-	defer db.Close()
+	return guildID, err
+}
 
-	tableName := "userscores"
-	sqlStmt := fmt.Sprintf("DROP TABLE IF EXISTS %s;", tableName)
-	_, err = db.Exec(sqlStmt)
+// PrintScoreTable 今日积分榜的文字版，每名玩家一行
+func PrintScoreTable(ctx context.Context, store Storage, scope BoardScope) (string, error) {
+	board, err := store.QueryLeaderboard(ctx, scope, startOfDay(time.Now()), time.Time{})
 	if err != nil {
-		log.Fatalf("Failed to delete table %s: %v", tableName, err)
+		return "", err
 	}
-	fmt.Printf("Table %s deleted successfully.\n", tableName)
-	createTableSQL := `  
-	CREATE TABLE IF NOT EXISTS userscores (  
-		id TEXT PRIMARY KEY,  
-		score INTEGER NOT NULL  
-	);`
-	_, err = db.Exec(createTableSQL)
+
+	data := ""
+	// 逐行读取并打印用户信息
+	for _, e := range board {
+		data += "\n" + strconv.Itoa(e.Rank) + ". " + e.Name + "   " + strconv.Itoa(e.Score)
+	}
+	return data, nil
+}
+
+// ErrNoPlayer 玩家还没有资料
+var ErrNoPlayer = errors.New("player not found")
+
+// Player 玩家资料，玩家第一次与机器人互动时自动建立
+type Player struct {
+	UserID      string      `json:"user_id"`
+	Nickname    string      `json:"nickname"` // 最近一次互动时的名字
+	Avatar      string      `json:"avatar"`
+	FirstSeen   time.Time   `json:"first_seen"`
+	LastSeen    time.Time   `json:"last_seen"`
+	GamesPlayed int         `json:"games_played"` // 参与过的成语接龙局数，含多人接龙
+	BestStreak  int         `json:"best_streak"`  // 单人成语接龙的最长连击
+	Prefs       PlayerPrefs `json:"preferences"`
+}
+
+// PlayerPrefs 玩家的偏好设置
+type PlayerPrefs struct {
+	Mode       string `json:"mode,omitempty"`       // 开局时默认的接龙模式
+	Difficulty string `json:"difficulty,omitempty"` // 开局时默认的难度
+}
+
+// playerColumns 查询玩家资料的列，与 scanPlayer 一致
+const playerColumns = "user_id, nickname, avatar, first_seen, last_seen, games_played, best_streak, preferences"
+
+// scanPlayer 读取一行玩家资料
+func scanPlayer(row interface{ Scan(...interface{}) error }) (Player, error) {
+	var p Player
+	var firstSeen, lastSeen int64
+	var prefs string
+	err := row.Scan(&p.UserID, &p.Nickname, &p.Avatar, &firstSeen, &lastSeen, &p.GamesPlayed, &p.BestStreak, &prefs)
 	if err != nil {
-		panic(err.Error())
+		return p, err
 	}
+	p.FirstSeen, p.LastSeen = time.Unix(firstSeen, 0), time.Unix(lastSeen, 0)
+	if err := json.Unmarshal([]byte(prefs), &p.Prefs); err != nil {
+		return p, fmt.Errorf("invalid preferences of player %s: %w", p.UserID, err)
+	}
+	return p, nil
 }
 
-func UpdateScore(username string, score int) error {
-	db, err := sql.Open("sqlite3", "./userscores.db")
+// SeePlayer 记录玩家的一次互动：第一次出现时建立资料，之后更新名字、头像和最近活跃时间，
+// 返回包括当天在内连续活跃的天数
+func (s *Store) SeePlayer(ctx context.Context, userID, nickname, avatar string, at time.Time) (int, error) {
+	var lastSeen int64
+	var streak int
+	err := s.db.QueryRowContext(ctx, s.rebind("SELECT last_seen, day_streak FROM players WHERE user_id = ?"), userID).
+		Scan(&lastSeen, &streak)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to query player: %w", err)
+	}
+	today := startOfDay(at)
+	switch last := startOfDay(time.Unix(lastSeen, 0)); {
+	case err == sql.ErrNoRows:
+		streak = 1
+	case !last.Before(today):
+		// 0006 迁移之前建立的资料没有记录连续天数
+		if streak == 0 {
+			streak = 1
+		}
+	case last.Equal(today.AddDate(0, 0, -1)):
+		streak++
+	default:
+		streak = 1
+	}
+
+	_, err = s.db.ExecContext(ctx, s.rebind(`
+	INSERT INTO players (user_id, nickname, avatar, first_seen, last_seen, day_streak) VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (user_id) DO UPDATE SET nickname = excluded.nickname, avatar = excluded.avatar,
+		last_seen = excluded.last_seen, day_streak = excluded.day_streak;`),
+		userID, nickname, avatar, at.Unix(), at.Unix(), streak,
+	)
 	if err != nil {
-		log.Fatal(err)
+		return 0, fmt.Errorf("failed to save player: %w", err)
 	}
-	defer db.Close()
-	var exists bool
-	errs := db.QueryRow("SELECT EXISTS(SELECT 1 FROM userscores WHERE id = ? AND score > ?)", username, score).Scan(&exists)
-	if errs != nil {
-		return errs
+	return streak, nil
+}
+
+// QueryPlayer 查询玩家资料，没有资料时返回 ErrNoPlayer
+func (s *Store) QueryPlayer(ctx context.Context, userID string) (Player, error) {
+	p, err := scanPlayer(s.db.QueryRowContext(ctx, s.rebind("SELECT "+playerColumns+" FROM players WHERE user_id = ?"), userID))
+	if err == sql.ErrNoRows {
+		return p, ErrNoPlayer
 	}
+	return p, err
+}
 
-	// 如果ID存在，则更新score
-	if exists {
-		// 准备更新语句
-		_, err = db.Exec("UPDATE userscores SET score = ? WHERE id = ?", score, username)
+// FindPlayers 按名字查找玩家，最近活跃的在前，最多返回 limit 个
+func (s *Store) FindPlayers(ctx context.Context, nickname string, limit int) ([]Player, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(
+		"SELECT "+playerColumns+" FROM players WHERE nickname LIKE ? ORDER BY last_seen DESC LIMIT ?"),
+		"%"+nickname+"%", limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var players []Player
+	for rows.Next() {
+		p, err := scanPlayer(rows)
 		if err != nil {
-			return err
+			return nil, err
 		}
-	} else {
-		Insert(username, score)
+		players = append(players, p)
 	}
+	return players, rows.Err()
+}
 
+// RecordGame 玩家结束一局游戏，局数加一，streak 超过最长连击时更新
+func (s *Store) RecordGame(ctx context.Context, userID string, streak int) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`
+	UPDATE players SET games_played = games_played + 1,
+		best_streak = CASE WHEN best_streak < ?1 THEN ?1 ELSE best_streak END
+	WHERE user_id = ?2;`), streak, userID)
+	if err != nil {
+		return fmt.Errorf("failed to record game: %w", err)
+	}
 	return nil
 }
 
-func AddUser(userid string) error {
-	db, err := sql.Open("sqlite3", "./userscores.db")
+// SavePreferences 保存玩家的偏好设置
+func (s *Store) SavePreferences(ctx context.Context, userID string, prefs PlayerPrefs) error {
+	b, err := json.Marshal(prefs)
 	if err != nil {
-		fmt.Println("打不开数据库！")
-		panic(err.Error())
+		return err
 	}
-	defer db.Close()
-	insertUserSQL := `  
-	INSERT INTO users (id) VALUES (?);  
-	`
-
-	_, errs := db.Exec(insertUserSQL, userid)
+	res, err := s.db.ExecContext(ctx, s.rebind("UPDATE players SET preferences = ? WHERE user_id = ?"), string(b), userID)
 	if err != nil {
-		return fmt.Errorf("failed to insert user: %w", errs)
-	} else {
-		fmt.Println("插入成功！！！")
+		return fmt.Errorf("failed to save preferences: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNoPlayer
 	}
-
 	return nil
 }
 
-func DeleteUser(userid string) error {
-	db, err := sql.Open("sqlite3", "./userscores.db")
+// UnlockedAchievement 玩家已解锁的一个成就
+type UnlockedAchievement struct {
+	ID         string
+	UnlockedAt time.Time
+}
+
+// UnlockAchievement 为玩家解锁成就，已经解锁过时不做改动并返回 false
+func (s *Store) UnlockAchievement(ctx context.Context, userID, achievementID string, at time.Time) (bool, error) {
+	res, err := s.db.ExecContext(ctx, s.rebind(`
+	INSERT INTO achievements (user_id, achievement_id, unlocked_at) VALUES (?, ?, ?)
+	ON CONFLICT (user_id, achievement_id) DO NOTHING;`), userID, achievementID, at.Unix())
 	if err != nil {
-		log.Fatal(err)
+		return false, fmt.Errorf("failed to unlock achievement: %w", err)
 	}
-	defer db.Close()
-	deleteStmt, err := db.Prepare("DELETE FROM user WHERE id = ?")
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// QueryAchievements 玩家已解锁的成就，先解锁的在前
+func (s *Store) QueryAchievements(ctx context.Context, userID string) ([]UnlockedAchievement, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(
+		"SELECT achievement_id, unlocked_at FROM achievements WHERE user_id = ? ORDER BY unlocked_at ASC, achievement_id ASC"),
+		userID,
+	)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer rows.Close()
+
+	var unlocked []UnlockedAchievement
+	for rows.Next() {
+		var a UnlockedAchievement
+		var at int64
+		if err := rows.Scan(&a.ID, &at); err != nil {
+			return nil, err
+		}
+		a.UnlockedAt = time.Unix(at, 0)
+		unlocked = append(unlocked, a)
 	}
-	defer deleteStmt.Close()
+	return unlocked, rows.Err()
+}
 
-	// 执行删除操作
-	_, err = deleteStmt.Exec(userid)
+// ListScores 按时间顺序列出积分流水，guildID 为空时列出全部频道的
+func (s *Store) ListScores(ctx context.Context, guildID string) ([]ScoreRecord, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`
+	SELECT user_id, guild_id, channel_id, display_name, score, created_at FROM score_ledger
+	WHERE ?1 = '' OR guild_id = ?1
+	ORDER BY created_at ASC, id ASC;`), guildID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	return nil
+	var records []ScoreRecord
+	for rows.Next() {
+		var r ScoreRecord
+		var createdAt int64
+		if err := rows.Scan(&r.UserID, &r.GuildID, &r.ChannelID, &r.Name, &r.Score, &createdAt); err != nil {
+			return nil, err
+		}
+		r.CreatedAt = time.Unix(createdAt, 0)
+		records = append(records, r)
+	}
+	return records, rows.Err()
 }
 
-func QueryUser(userid string) bool {
-	db, err := sql.Open("sqlite3", "./userscores.db")
+// ListPlayers 按首次互动的时间顺序列出全部玩家资料
+func (s *Store) ListPlayers(ctx context.Context) ([]Player, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+playerColumns+" FROM players ORDER BY first_seen ASC, user_id ASC")
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	defer db.Close()
+	defer rows.Close()
 
-	var exists bool
-	errs := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", userid).Scan(&exists)
-	if errs != nil {
-		return false
+	var players []Player
+	for rows.Next() {
+		p, err := scanPlayer(rows)
+		if err != nil {
+			return nil, err
+		}
+		players = append(players, p)
+	}
+	return players, rows.Err()
+}
+
+// ImportResult 导入的结果
+type ImportResult struct {
+	ScoresAdded   int // 新增的积分流水
+	ScoresSkipped int // 已存在而跳过的积分流水
+	PlayersAdded  int // 新增的玩家资料
+	PlayersMerged int // 与已有资料合并的玩家
+}
+
+// Import 在一个事务中合并导入积分流水与玩家资料，dryRun 时只读取数据库统计结果，不做任何写入，
+// 可以在只读打开的数据库上执行。
+// 玩家、频道、子频道、分数与时间都相同的积分流水视为已存在；
+// 已有的玩家资料取两边最早的首次互动、最近的活跃时间和较大的局数与连击，名字、头像以较近活跃的一方为准
+func (s *Store) Import(ctx context.Context, scores []ScoreRecord, players []Player, dryRun bool) (ImportResult, error) {
+	var result ImportResult
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	// 本次导入已经处理过的数据，dryRun 时没有写入数据库，文件中重复的行靠它判断
+	type scoreKey struct {
+		userID, guildID, channelID string
+		score                      int
+		at                         int64
+	}
+	seenScores := map[scoreKey]bool{}
+	seenPlayers := map[string]Player{}
+
+	for _, r := range scores {
+		key := scoreKey{r.UserID, r.GuildID, r.ChannelID, r.Score, r.CreatedAt.Unix()}
+		exists := seenScores[key]
+		if !exists {
+			err := tx.QueryRowContext(ctx, s.rebind(`
+			SELECT EXISTS(SELECT 1 FROM score_ledger
+			WHERE user_id = ? AND guild_id = ? AND channel_id = ? AND score = ? AND created_at = ?)`),
+				r.UserID, r.GuildID, r.ChannelID, r.Score, r.CreatedAt.Unix(),
+			).Scan(&exists)
+			if err != nil {
+				return result, err
+			}
+		}
+		if exists {
+			result.ScoresSkipped++
+			continue
+		}
+		seenScores[key] = true
+		result.ScoresAdded++
+		if dryRun {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, s.rebind(
+			"INSERT INTO score_ledger (user_id, guild_id, channel_id, display_name, score, created_at) VALUES (?, ?, ?, ?, ?, ?)"),
+			r.UserID, r.GuildID, r.ChannelID, r.Name, r.Score, r.CreatedAt.Unix(),
+		)
+		if err != nil {
+			return result, fmt.Errorf("failed to import score: %w", err)
+		}
+	}
+
+	for _, p := range players {
+		old, ok := seenPlayers[p.UserID]
+		if !ok {
+			old, err = scanPlayer(tx.QueryRowContext(ctx, s.rebind("SELECT "+playerColumns+" FROM players WHERE user_id = ?"), p.UserID))
+			ok = err == nil
+			if err != nil && err != sql.ErrNoRows {
+				return result, err
+			}
+		}
+		if ok {
+			p = mergePlayer(old, p)
+			result.PlayersMerged++
+		} else {
+			result.PlayersAdded++
+		}
+		seenPlayers[p.UserID] = p
+		if dryRun {
+			continue
+		}
+		prefs, err := json.Marshal(p.Prefs)
+		if err != nil {
+			return result, err
+		}
+		_, err = tx.ExecContext(ctx, s.rebind(`
+		INSERT INTO players (user_id, nickname, avatar, first_seen, last_seen, games_played, best_streak, preferences)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET nickname = excluded.nickname, avatar = excluded.avatar,
+			first_seen = excluded.first_seen, last_seen = excluded.last_seen, games_played = excluded.games_played,
+			best_streak = excluded.best_streak, preferences = excluded.preferences;`),
+			p.UserID, p.Nickname, p.Avatar, p.FirstSeen.Unix(), p.LastSeen.Unix(), p.GamesPlayed, p.BestStreak, string(prefs),
+		)
+		if err != nil {
+			return result, fmt.Errorf("failed to import player: %w", err)
+		}
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	return result, tx.Commit()
+}
+
+// mergePlayer 合并同一玩家的两份资料
+func mergePlayer(old, imported Player) Player {
+	merged := old
+	if imported.LastSeen.After(old.LastSeen) {
+		merged.Nickname, merged.Avatar, merged.LastSeen = imported.Nickname, imported.Avatar, imported.LastSeen
+	}
+	if imported.FirstSeen.Before(old.FirstSeen) {
+		merged.FirstSeen = imported.FirstSeen
+	}
+	if imported.GamesPlayed > old.GamesPlayed {
+		merged.GamesPlayed = imported.GamesPlayed
+	}
+	if imported.BestStreak > old.BestStreak {
+		merged.BestStreak = imported.BestStreak
+	}
+	if merged.Prefs == (PlayerPrefs{}) {
+		merged.Prefs = imported.Prefs
 	}
-	return exists
+	return merged
 }
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportVersion JSON 导出文件的格式版本
+const exportVersion = 1
+
+// ExportFile JSON 导出文件
+type ExportFile struct {
+	Version    int           `json:"version"`
+	ExportedAt time.Time     `json:"exported_at"`
+	Scores     []ScoreRecord `json:"scores,omitempty"`
+	Players    []Player      `json:"players,omitempty"`
+}
+
+// CSV 的表头，导入时按表头识别数据种类，列的顺序不限
+var (
+	scoreCSVHeader  = []string{"user_id", "guild_id", "channel_id", "display_name", "score", "created_at"}
+	playerCSVHeader = []string{"user_id", "nickname", "avatar", "first_seen", "last_seen", "games_played", "best_streak", "preferences"}
+)
+
+// formatTime 导出文件中的时间，RFC3339 格式
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+// parseTime 解析导入文件中的时间，支持 RFC3339 和 Unix 秒数
+func parseTime(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// csvFormulaChars 表格软件会当作公式执行的开头字符，以及用于转义的单引号本身
+const csvFormulaChars = "=+-@\t\r'"
+
+// escapeCSVCell 文本单元格以公式字符开头时加上单引号，避免表格软件打开时执行玩家名字等内容中的公式
+func escapeCSVCell(s string) string {
+	if s != "" && strings.ContainsRune(csvFormulaChars, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// unescapeCSVCell 去掉 escapeCSVCell 加上的单引号
+func unescapeCSVCell(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(csvFormulaChars, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// writeScoresCSV 以 CSV 写出积分流水
+func writeScoresCSV(w io.Writer, records []ScoreRecord) error {
+	cw := csv.NewWriter(w)
+	cw.Write(scoreCSVHeader)
+	for _, r := range records {
+		cw.Write([]string{
+			escapeCSVCell(r.UserID), escapeCSVCell(r.GuildID), escapeCSVCell(r.ChannelID), escapeCSVCell(r.Name),
+			strconv.Itoa(r.Score), formatTime(r.CreatedAt),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writePlayersCSV 以 CSV 写出玩家资料，偏好设置写作 JSON
+func writePlayersCSV(w io.Writer, players []Player) error {
+	cw := csv.NewWriter(w)
+	cw.Write(playerCSVHeader)
+	for _, p := range players {
+		prefs, err := json.Marshal(p.Prefs)
+		if err != nil {
+			return err
+		}
+		cw.Write([]string{
+			escapeCSVCell(p.UserID), escapeCSVCell(p.Nickname), escapeCSVCell(p.Avatar), formatTime(p.FirstSeen), formatTime(p.LastSeen),
+			strconv.Itoa(p.GamesPlayed), strconv.Itoa(p.BestStreak), string(prefs),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ImportErrors 导入文件中校验不通过的行
+type ImportErrors []string
+
+func (e ImportErrors) Error() string {
+	return fmt.Sprintf("%d invalid rows:\n%s", len(e), strings.Join(e, "\n"))
+}
+
+// validateScore 校验一条导入的积分流水，频道可以为空：迁移来的旧积分不属于任何频道，只计入全服总榜
+func validateScore(r ScoreRecord) error {
+	switch {
+	case r.UserID == "":
+		return errors.New("user_id is empty")
+	case r.CreatedAt.IsZero():
+		return errors.New("created_at is empty")
+	}
+	return nil
+}
+
+// validatePlayer 校验一份导入的玩家资料，没有最近活跃时间时取首次互动时间
+func validatePlayer(p *Player) error {
+	switch {
+	case p.UserID == "":
+		return errors.New("user_id is empty")
+	case p.FirstSeen.IsZero():
+		return errors.New("first_seen is empty")
+	case p.GamesPlayed < 0 || p.BestStreak < 0:
+		return errors.New("games_played and best_streak must not be negative")
+	}
+	if p.LastSeen.IsZero() {
+		p.LastSeen = p.FirstSeen
+	}
+	return nil
+}
+
+// readCSV 读取导入的 CSV，按表头识别是积分流水还是玩家资料，校验不通过的行以 ImportErrors 返回
+func readCSV(r io.Reader) ([]ScoreRecord, []Player, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	col := map[string]int{}
+	// 表格软件另存的 CSV 可能带有 BOM
+	for i, name := range header {
+		col[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	_, isScores := col["score"]
+	expected := scoreCSVHeader
+	if !isScores {
+		expected = playerCSVHeader
+	}
+	for _, name := range expected {
+		if _, ok := col[name]; !ok {
+			return nil, nil, fmt.Errorf("csv header must contain %s", strings.Join(expected, ","))
+		}
+	}
+
+	var scores []ScoreRecord
+	var players []Player
+	var invalid ImportErrors
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		field := func(name string) string { return unescapeCSVCell(strings.TrimSpace(row[col[name]])) }
+		if isScores {
+			r, err := scoreFromCSV(field)
+			if err == nil {
+				err = validateScore(r)
+			}
+			if err != nil {
+				invalid = append(invalid, fmt.Sprintf("line %d: %v", line, err))
+				continue
+			}
+			scores = append(scores, r)
+		} else {
+			p, err := playerFromCSV(field)
+			if err == nil {
+				err = validatePlayer(&p)
+			}
+			if err != nil {
+				invalid = append(invalid, fmt.Sprintf("line %d: %v", line, err))
+				continue
+			}
+			players = append(players, p)
+		}
+	}
+	if len(invalid) > 0 {
+		return scores, players, invalid
+	}
+	return scores, players, nil
+}
+
+// scoreFromCSV 按列名读取一条积分流水
+func scoreFromCSV(field func(name string) string) (ScoreRecord, error) {
+	r := ScoreRecord{
+		UserID:    field("user_id"),
+		GuildID:   field("guild_id"),
+		ChannelID: field("channel_id"),
+		Name:      field("display_name"),
+	}
+	var err error
+	if r.Score, err = strconv.Atoi(field("score")); err != nil {
+		return r, fmt.Errorf("invalid score %q", field("score"))
+	}
+	if r.CreatedAt, err = parseTime(field("created_at")); err != nil {
+		return r, fmt.Errorf("invalid created_at %q", field("created_at"))
+	}
+	return r, nil
+}
+
+// playerFromCSV 按列名读取一份玩家资料
+func playerFromCSV(field func(name string) string) (Player, error) {
+	p := Player{
+		UserID:   field("user_id"),
+		Nickname: field("nickname"),
+		Avatar:   field("avatar"),
+	}
+	var err error
+	if p.FirstSeen, err = parseTime(field("first_seen")); err != nil {
+		return p, fmt.Errorf("invalid first_seen %q", field("first_seen"))
+	}
+	if s := field("last_seen"); s != "" {
+		if p.LastSeen, err = parseTime(s); err != nil {
+			return p, fmt.Errorf("invalid last_seen %q", s)
+		}
+	}
+	if s := field("games_played"); s != "" {
+		if p.GamesPlayed, err = strconv.Atoi(s); err != nil {
+			return p, fmt.Errorf("invalid games_played %q", s)
+		}
+	}
+	if s := field("best_streak"); s != "" {
+		if p.BestStreak, err = strconv.Atoi(s); err != nil {
+			return p, fmt.Errorf("invalid best_streak %q", s)
+		}
+	}
+	if s := field("preferences"); s != "" {
+		if err = json.Unmarshal([]byte(s), &p.Prefs); err != nil {
+			return p, fmt.Errorf("invalid preferences %q", s)
+		}
+	}
+	return p, nil
+}
+
+// readJSON 读取导入的 JSON 导出文件，校验不通过的记录以 ImportErrors 返回
+func readJSON(r io.Reader) ([]ScoreRecord, []Player, error) {
+	var file ExportFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, nil, fmt.Errorf("failed to read json: %w", err)
+	}
+	if file.Version != exportVersion {
+		return nil, nil, fmt.Errorf("unsupported export version %d", file.Version)
+	}
+	var scores []ScoreRecord
+	var players []Player
+	var invalid ImportErrors
+	for i, s := range file.Scores {
+		if err := validateScore(s); err != nil {
+			invalid = append(invalid, fmt.Sprintf("scores[%d]: %v", i, err))
+			continue
+		}
+		scores = append(scores, s)
+	}
+	for i, p := range file.Players {
+		if err := validatePlayer(&p); err != nil {
+			invalid = append(invalid, fmt.Sprintf("players[%d]: %v", i, err))
+			continue
+		}
+		players = append(players, p)
+	}
+	if len(invalid) > 0 {
+		return scores, players, invalid
+	}
+	return scores, players, nil
+}
+
+// leaderboardCSV 积分榜的 CSV，便于在表格软件中计算奖励
+func leaderboardCSV(board []LeaderboardEntry) []byte {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.Write([]string{"rank", "user_id", "name", "score"})
+	for _, e := range board {
+		cw.Write([]string{strconv.Itoa(e.Rank), escapeCSVCell(e.UserID), escapeCSVCell(e.Name), strconv.Itoa(e.Score)})
+	}
+	cw.Flush()
+	return buf.Bytes()
+}
+
+// splitLines 按行把文本切成不超过 limit 字节的若干段，单行超过 limit 时单独成段
+func splitLines(text string, limit int) []string {
+	var chunks []string
+	var cur strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if cur.Len() > 0 && cur.Len()+len(line) > limit {
+			chunks = append(chunks, cur.String())
+			cur.Reset()
+		}
+		cur.WriteString(line)
+	}
+	if cur.Len() > 0 {
+		chunks = append(chunks, cur.String())
+	}
+	return chunks
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVFormulaEscape(t *testing.T) {
+	names := []string{"=HYPERLINK(\"http://x\")", "+1", "-1", "@SUM(A1)", "'=quoted", "'plain", "小明", ""}
+	var records []ScoreRecord
+	for _, name := range names {
+		records = append(records, ScoreRecord{UserID: "u1", GuildID: "g1", ChannelID: "c1", Name: name, Score: -3, CreatedAt: testNow})
+	}
+	var buf bytes.Buffer
+	if err := writeScoresCSV(&buf, records); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\n")[1:] {
+		for _, cell := range strings.Split(line, ",") {
+			if cell != "" && strings.ContainsRune("=+@", rune(cell[0])) {
+				t.Errorf("unescaped formula cell %q", cell)
+			}
+		}
+	}
+	// 分数是数字，负分不加引号
+	if !strings.Contains(buf.String(), ",-3,") {
+		t.Errorf("numeric cells should not be escaped: %s", buf.String())
+	}
+
+	// 导出后再导入，内容不变
+	scores, _, err := readCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range scores {
+		if r.Name != names[i] {
+			t.Errorf("round trip name = %q, want %q", r.Name, names[i])
+		}
+	}
+
+	board := leaderboardCSV([]LeaderboardEntry{{Rank: 1, UserID: "u1", Name: "=1+1", Score: 5}})
+	if !strings.Contains(string(board), "1,u1,'=1+1,5") {
+		t.Errorf("leaderboard csv is not escaped: %s", board)
+	}
+}
+
+func TestImportDryRunReadOnly(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "bot.db")
+	store := openTestStore(t, file)
+	recordScores(t, store, ScoreRecord{UserID: "u1", GuildID: "g1", ChannelID: "c1", Name: "小明", Score: 5, CreatedAt: testNow})
+	store.Close()
+	before, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := OpenStorageReadOnly(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if err := ro.RecordScore(ctx, ScoreRecord{UserID: "u2", GuildID: "g1", ChannelID: "c1", CreatedAt: testNow}); err == nil {
+		t.Error("read-only store accepted a write")
+	}
+
+	// 文件中重复的行与数据库中已有的行都算跳过
+	dup := ScoreRecord{UserID: "u2", GuildID: "g1", ChannelID: "c1", Name: "小红", Score: 3, CreatedAt: testNow}
+	scores := []ScoreRecord{{UserID: "u1", GuildID: "g1", ChannelID: "c1", Name: "小明", Score: 5, CreatedAt: testNow}, dup, dup}
+	players := []Player{{UserID: "u2", Nickname: "小红"}, {UserID: "u2", Nickname: "红红", GamesPlayed: 2}}
+	result, err := ro.Import(ctx, scores, players, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ImportResult{ScoresAdded: 1, ScoresSkipped: 2, PlayersAdded: 1, PlayersMerged: 1}
+	if result != want {
+		t.Errorf("dry run result = %+v, want %+v", result, want)
+	}
+	ro.Close()
+
+	after, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("dry run modified the database file")
+	}
+}
+
+func TestExportImportLegacyScores(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := sql.Open(sqliteDialect.driver, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := legacy.Exec(`
+	CREATE TABLE userscores (id TEXT PRIMARY KEY, score INTEGER NOT NULL);
+	INSERT INTO userscores (id, score) VALUES ('bob', 12);`); err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+	store := openTestStore(t, file)
+	recordScores(t, store, ScoreRecord{UserID: "u1", GuildID: "g1", ChannelID: "c1", Name: "小明", Score: 5, CreatedAt: testNow})
+
+	exported, err := store.ListScores(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeScoresCSV(&buf, exported); err != nil {
+		t.Fatal(err)
+	}
+	scores, _, err := readCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 2 {
+		t.Fatalf("read %d scores, want 2", len(scores))
+	}
+
+	// 导入到新数据库后旧积分仍然没有频道，只计入全服总榜
+	target := newTestStore(t)
+	result, err := target.Import(ctx, scores, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ScoresAdded != 2 {
+		t.Errorf("import result = %+v, want 2 scores added", result)
+	}
+	board, err := target.QueryLeaderboard(ctx, BoardScope{}, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(board) != 2 || board[0].UserID != "legacy:bob" || board[0].Score != 12 {
+		t.Errorf("global board after import = %+v", board)
+	}
+	if list, _ := target.ListScores(ctx, "g1"); len(list) != 1 || list[0].UserID != "u1" {
+		t.Errorf("ListScores(g1) after import = %+v", list)
+	}
+
+	// 再导入一次全部跳过
+	if result, err = target.Import(ctx, scores, nil, false); err != nil || result.ScoresSkipped != 2 {
+		t.Errorf("second import = %+v, %v, want 2 scores skipped", result, err)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// ErrNoGame 玩家没有进行中的游戏
+var ErrNoGame = errors.New("game not found")
+
+// ErrGameExists 玩家已有进行中的游戏
+var ErrGameExists = errors.New("game already in progress")
+
+// GameKey 一局成语接龙的唯一标识，同一玩家在不同频道、子频道的游戏互不影响
+type GameKey struct {
+	GuildID   string
+	ChannelID string
+	UserID    string
+}
+
+// gameKeyOf 消息发送者在当前子频道的游戏
+func gameKeyOf(data *Message) GameKey {
+	return GameKey{GuildID: data.GuildID, ChannelID: data.ChannelID, UserID: data.Author.ID}
+}
+
+// GameStore 成语接龙的游戏状态存储，实现需要保证并发安全
+type GameStore interface {
+	// Create 开始新的一局，该玩家已有进行中的游戏时不覆盖，返回 ErrGameExists
+	Create(key GameKey, game *IdiomUser) error
+	// Update 在锁内修改进行中的游戏并保存，fn 返回错误时不保存，游戏不存在时返回 ErrNoGame
+	Update(key GameKey, fn func(game *IdiomUser) error) error
+	// Get 进行中的游戏，游戏不存在时返回 ErrNoGame
+	Get(key GameKey) (*IdiomUser, error)
+	// Finish 结束进行中的游戏并记为该玩家的上一局，返回结束时的状态
+	// fn 不为 nil 时先在锁内检查或修改结束时的状态，返回错误时不结束游戏并返回该错误
+	Finish(key GameKey, fn func(game *IdiomUser) error) (*IdiomUser, error)
+	// Last 该玩家最近一局已结束的游戏，没有时返回 ErrNoGame
+	Last(key GameKey) (*IdiomUser, error)
+	// SetLast 更新该玩家的上一局，用于结束后补上实际计入的得分
+	SetLast(key GameKey, game *IdiomUser) error
+	// All 全部进行中的游戏，用于重启后恢复计时
+	All() (map[GameKey]*IdiomUser, error)
+}
+
+// NewGameStore 按配置创建游戏状态存储，database 存储与积分共用数据库，重启后仍能继续进行中的游戏
+func NewGameStore(kind string, store Storage) (GameStore, error) {
+	switch kind {
+	case "", "memory":
+		return NewMemoryGameStore(), nil
+	case "database":
+		return store.GameStore(), nil
+	default:
+		return nil, errors.New("unsupported game store: " + kind)
+	}
+}
+
+// gameShards 内存存储的分片数
+const gameShards = 16
+
+type gameShard struct {
+	mu    sync.Mutex
+	games map[GameKey]*IdiomUser
+	last  map[GameKey]*IdiomUser
+}
+
+// MemoryGameStore 分片加锁的内存存储，重启后游戏状态丢失
+type MemoryGameStore struct {
+	shards [gameShards]gameShard
+}
+
+// NewMemoryGameStore 创建内存存储
+func NewMemoryGameStore() *MemoryGameStore {
+	s := &MemoryGameStore{}
+	for i := range s.shards {
+		s.shards[i].games = map[GameKey]*IdiomUser{}
+		s.shards[i].last = map[GameKey]*IdiomUser{}
+	}
+	return s
+}
+
+func (s *MemoryGameStore) shard(key GameKey) *gameShard {
+	h := fnv.New32a()
+	h.Write([]byte(key.GuildID + "/" + key.ChannelID + "/" + key.UserID))
+	return &s.shards[h.Sum32()%gameShards]
+}
+
+// Create 开始新的一局
+func (s *MemoryGameStore) Create(key GameKey, game *IdiomUser) error {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, ok := sh.games[key]; ok {
+		return ErrGameExists
+	}
+	sh.games[key] = game.clone()
+	return nil
+}
+
+// Update 在锁内修改进行中的游戏
+func (s *MemoryGameStore) Update(key GameKey, fn func(game *IdiomUser) error) error {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	game, ok := sh.games[key]
+	if !ok {
+		return ErrNoGame
+	}
+	updated := game.clone()
+	if err := fn(updated); err != nil {
+		return err
+	}
+	sh.games[key] = updated
+	return nil
+}
+
+// Get 进行中的游戏
+func (s *MemoryGameStore) Get(key GameKey) (*IdiomUser, error) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	game, ok := sh.games[key]
+	if !ok {
+		return nil, ErrNoGame
+	}
+	return game.clone(), nil
+}
+
+// Finish 结束进行中的游戏
+func (s *MemoryGameStore) Finish(key GameKey, fn func(game *IdiomUser) error) (*IdiomUser, error) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	game, ok := sh.games[key]
+	if !ok {
+		return nil, ErrNoGame
+	}
+	if fn != nil {
+		game = game.clone()
+		if err := fn(game); err != nil {
+			return nil, err
+		}
+	}
+	delete(sh.games, key)
+	sh.last[key] = game
+	return game.clone(), nil
+}
+
+// Last 最近一局已结束的游戏
+func (s *MemoryGameStore) Last(key GameKey) (*IdiomUser, error) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	game, ok := sh.last[key]
+	if !ok {
+		return nil, ErrNoGame
+	}
+	return game.clone(), nil
+}
+
+// SetLast 更新该玩家的上一局
+func (s *MemoryGameStore) SetLast(key GameKey, game *IdiomUser) error {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.last[key] = game.clone()
+	return nil
+}
+
+// All 全部进行中的游戏
+func (s *MemoryGameStore) All() (map[GameKey]*IdiomUser, error) {
+	all := map[GameKey]*IdiomUser{}
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		for key, game := range sh.games {
+			all[key] = game.clone()
+		}
+		sh.mu.Unlock()
+	}
+	return all, nil
+}
+
+// clone 深拷贝游戏状态，存储内外的修改互不影响
+func (u *IdiomUser) clone() *IdiomUser {
+	c := *u
+	c.used = make(map[string]bool, len(u.used))
+	for word := range u.used {
+		c.used[word] = true
+	}
+	c.chain = append([]ChainStep(nil), u.chain...)
+	return &c
+}
+
+// gameState 游戏状态的序列化格式
+type gameState struct {
+	Score      int            `json:"score"`
+	LastWord   string         `json:"last_word"`
+	LastPinyin string         `json:"last_pinyin"`
+	Mode       ChainMode      `json:"mode"`
+	Difficulty Difficulty     `json:"difficulty"`
+	Streak     int            `json:"streak"`
+	BestStreak int            `json:"best_streak,omitempty"`
+	Hinted     bool           `json:"hinted"`
+	TurnStart  time.Time      `json:"turn_start"`
+	Breakdown  ScoreBreakdown `json:"breakdown"`
+	HintWord   string         `json:"hint_word,omitempty"`
+	HintLevel  int            `json:"hint_level,omitempty"`
+	HintsUsed  int            `json:"hints_used,omitempty"`
+	Used       []string       `json:"used"`
+	Chain      []ChainStep    `json:"chain"`
+	LastMsg    *Message       `json:"last_msg,omitempty"`
+}
+
+// MarshalJSON 序列化游戏状态
+func (u *IdiomUser) MarshalJSON() ([]byte, error) {
+	s := gameState{
+		Score:      u.score,
+		LastPinyin: u.lastPinyin,
+		Mode:       u.mode,
+		Difficulty: u.difficulty,
+		Streak:     u.streak,
+		BestStreak: u.bestStreak,
+		Hinted:     u.hinted,
+		TurnStart:  u.turnStart,
+		Breakdown:  u.breakdown,
+		HintWord:   u.hintWord,
+		HintLevel:  u.hintLevel,
+		HintsUsed:  u.hintsUsed,
+		Chain:      u.chain,
+		LastMsg:    u.lastMsg,
+	}
+	if u.lastward != 0 {
+		s.LastWord = string(u.lastward)
+	}
+	for word := range u.used {
+		s.Used = append(s.Used, word)
+	}
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON 反序列化游戏状态
+func (u *IdiomUser) UnmarshalJSON(b []byte) error {
+	var s gameState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*u = IdiomUser{
+		score:      s.Score,
+		lastPinyin: s.LastPinyin,
+		mode:       s.Mode,
+		difficulty: s.Difficulty,
+		streak:     s.Streak,
+		bestStreak: s.BestStreak,
+		hinted:     s.Hinted,
+		turnStart:  s.TurnStart,
+		breakdown:  s.Breakdown,
+		hintWord:   s.HintWord,
+		hintLevel:  s.HintLevel,
+		hintsUsed:  s.HintsUsed,
+		used:       map[string]bool{},
+		chain:      s.Chain,
+		lastMsg:    s.LastMsg,
+	}
+	for _, r := range s.LastWord {
+		u.lastward = r
+		break
+	}
+	for _, word := range s.Used {
+		u.used[word] = true
+	}
+	return nil
+}
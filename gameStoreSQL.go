@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// SQLGameStore 保存在数据库中的游戏状态，重启后进行中的游戏可以继续
+// 每局的状态以 JSON 保存，finished 为 1 的记录是该玩家的上一局
+// 所有读写都在 mu 内串行进行，保证 Update 读改写的原子性
+type SQLGameStore struct {
+	mu      sync.Mutex
+	db      *sql.DB
+	dialect dialect
+}
+
+// NewSQLGameStore 使用已打开的数据库保存游戏状态，数据表由迁移脚本创建，数据库由调用方负责关闭
+func NewSQLGameStore(db *sql.DB, d dialect) *SQLGameStore {
+	return &SQLGameStore{db: db, dialect: d}
+}
+
+// sqlQuerier *sql.DB 与 *sql.Tx 共有的读写方法，load 与 save 可以在事务内外使用
+type sqlQuerier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (s *SQLGameStore) load(q sqlQuerier, key GameKey, finished int) (*IdiomUser, error) {
+	var state string
+	err := q.QueryRow(s.dialect.rebind(
+		"SELECT state FROM idiom_games WHERE guild_id = ? AND channel_id = ? AND user_id = ? AND finished = ?"),
+		key.GuildID, key.ChannelID, key.UserID, finished,
+	).Scan(&state)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoGame
+	}
+	if err != nil {
+		return nil, err
+	}
+	game := &IdiomUser{}
+	if err := json.Unmarshal([]byte(state), game); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+func (s *SQLGameStore) save(q sqlQuerier, key GameKey, finished int, game *IdiomUser) error {
+	state, err := json.Marshal(game)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(s.dialect.rebind(`
+	INSERT INTO idiom_games (guild_id, channel_id, user_id, finished, state, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (guild_id, channel_id, user_id, finished) DO UPDATE SET state = excluded.state, updated_at = excluded.updated_at;`),
+		key.GuildID, key.ChannelID, key.UserID, finished, string(state), time.Now().Unix(),
+	)
+	return err
+}
+
+// Create 开始新的一局
+func (s *SQLGameStore) Create(key GameKey, game *IdiomUser) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, err := json.Marshal(game)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(s.dialect.rebind(`
+	INSERT INTO idiom_games (guild_id, channel_id, user_id, finished, state, updated_at) VALUES (?, ?, ?, 0, ?, ?)
+	ON CONFLICT (guild_id, channel_id, user_id, finished) DO NOTHING;`),
+		key.GuildID, key.ChannelID, key.UserID, string(state), time.Now().Unix(),
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = ErrGameExists
+		}
+		return err
+	}
+	return nil
+}
+
+// Update 在锁内修改进行中的游戏
+func (s *SQLGameStore) Update(key GameKey, fn func(game *IdiomUser) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	game, err := s.load(s.db, key, 0)
+	if err != nil {
+		return err
+	}
+	if err := fn(game); err != nil {
+		return err
+	}
+	return s.save(s.db, key, 0, game)
+}
+
+// Get 进行中的游戏
+func (s *SQLGameStore) Get(key GameKey) (*IdiomUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(s.db, key, 0)
+}
+
+// Finish 结束进行中的游戏，读取、保存为上一局和删除进行中的记录在同一个事务中完成
+func (s *SQLGameStore) Finish(key GameKey, fn func(game *IdiomUser) error) (*IdiomUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var game *IdiomUser
+	err := inTx(context.Background(), s.db, func(tx *sql.Tx) error {
+		var err error
+		if game, err = s.load(tx, key, 0); err != nil {
+			return err
+		}
+		if fn != nil {
+			if err := fn(game); err != nil {
+				return err
+			}
+		}
+		if err := s.save(tx, key, 1, game); err != nil {
+			return err
+		}
+		_, err = tx.Exec(s.dialect.rebind(
+			"DELETE FROM idiom_games WHERE guild_id = ? AND channel_id = ? AND user_id = ? AND finished = 0"),
+			key.GuildID, key.ChannelID, key.UserID,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+// SetLast 更新该玩家的上一局
+func (s *SQLGameStore) SetLast(key GameKey, game *IdiomUser) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save(s.db, key, 1, game)
+}
+
+// Last 最近一局已结束的游戏
+func (s *SQLGameStore) Last(key GameKey) (*IdiomUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(s.db, key, 1)
+}
+
+// All 全部进行中的游戏
+func (s *SQLGameStore) All() (map[GameKey]*IdiomUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rows, err := s.db.Query("SELECT guild_id, channel_id, user_id, state FROM idiom_games WHERE finished = 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := map[GameKey]*IdiomUser{}
+	for rows.Next() {
+		var key GameKey
+		var state string
+		if err := rows.Scan(&key.GuildID, &key.ChannelID, &key.UserID, &state); err != nil {
+			return nil, err
+		}
+		game := &IdiomUser{}
+		if err := json.Unmarshal([]byte(state), game); err != nil {
+			return nil, err
+		}
+		all[key] = game
+	}
+	return all, rows.Err()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// errStaleTurn 计时器触发时回合已经结束，如玩家刚好答对
+var errStaleTurn = errors.New("turn is over")
+
+// turnTimer 回合计时器，到期前提醒一次，到期后回调
+// 每次重新计时都会开始新的回合，回调收到的回合编号与当前回合不一致时说明已过期，应当忽略
+type turnTimer struct {
+	round  int
+	timers []*time.Timer
+}
+
+// reset 开始新的回合并重新计时，返回新的回合编号
+func (t *turnTimer) reset(timeout, remindBefore time.Duration, remind, expire func(round int)) int {
+	t.stop()
+	t.round++
+	round := t.round
+	if remindBefore > 0 && remindBefore < timeout {
+		t.timers = append(t.timers, time.AfterFunc(timeout-remindBefore, func() { remind(round) }))
+	}
+	t.timers = append(t.timers, time.AfterFunc(timeout, func() { expire(round) }))
+	return round
+}
+
+// stop 停止计时，已触发的回调会因为回合编号过期而被忽略
+func (t *turnTimer) stop() {
+	for _, timer := range t.timers {
+		timer.Stop()
+	}
+	t.timers = nil
+	t.round++
+}
+
+// gameTimers 各玩家游戏的回合计时器，与游戏状态分开保存，重启后由 restoreGames 重新计时
+type gameTimers struct {
+	mu     sync.Mutex
+	timers map[GameKey]*turnTimer
+}
+
+func newGameTimers() *gameTimers {
+	return &gameTimers{timers: map[GameKey]*turnTimer{}}
+}
+
+// gameTurn 玩家的一个回合。计时器停止后会从表中删除，再开始的游戏使用新的计时器，
+// 回合编号从头计起，所以判断回合是否过期时要同时比较计时器
+type gameTurn struct {
+	timer *turnTimer
+	round int
+}
+
+// reset 玩家的新回合开始计时
+func (t *gameTimers) reset(key GameKey, timeout, remindBefore time.Duration, remind, expire func(turn gameTurn)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timer, ok := t.timers[key]
+	if !ok {
+		timer = &turnTimer{}
+		t.timers[key] = timer
+	}
+	timer.reset(timeout, remindBefore,
+		func(round int) { remind(gameTurn{timer: timer, round: round}) },
+		func(round int) { expire(gameTurn{timer: timer, round: round}) },
+	)
+}
+
+// stop 停止玩家的计时并删除计时器，已触发的回调会因为计时器不在表中而被忽略
+func (t *gameTimers) stop(key GameKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if timer, ok := t.timers[key]; ok {
+		timer.stop()
+		delete(t.timers, key)
+	}
+}
+
+// active 回合是否仍是玩家当前的回合
+func (t *gameTimers) active(key GameKey, turn gameTurn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timer, ok := t.timers[key]
+	return ok && timer == turn.timer && timer.round == turn.round
+}
+
+// turnTimeout 配置的每回合作答时限
+func turnTimeout() time.Duration {
+	if conf.Game.TurnTimeout > 0 {
+		return conf.Game.TurnTimeout
+	}
+	return defaultTurnTimeout
+}
+
+// notify 计时器触发时向子频道发送消息，仍在被动回复有效期内时回复最近一条相关消息
+func (p Processor) notify(data *Message, content string) {
+	toCreate := &MessageToCreate{Content: content}
+	if canPassiveReply(data, time.Now()) {
+		toCreate.MsgID = data.ID
+	}
+	if _, err := p.api.PostMessage(context.Background(), data.ChannelID, toCreate); err != nil {
+		log.Println(err)
+	}
+}
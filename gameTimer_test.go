@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGameTimersStop(t *testing.T) {
+	timers := newGameTimers()
+	key := GameKey{GuildID: "g1", ChannelID: "c1", UserID: "u1"}
+	noop := func(gameTurn) {}
+
+	timers.reset(key, time.Hour, 0, noop, noop)
+	old := gameTurn{timer: timers.timers[key], round: timers.timers[key].round}
+	if !timers.active(key, old) {
+		t.Fatal("current turn should be active")
+	}
+	timers.stop(key)
+	if len(timers.timers) != 0 {
+		t.Errorf("stopped timer is still kept: %v", timers.timers)
+	}
+
+	// 新游戏的计时器回合编号从头计起，旧回合不能被当成当前回合
+	timers.reset(key, time.Hour, 0, noop, noop)
+	defer timers.stop(key)
+	if timers.timers[key].round != old.round {
+		t.Fatalf("new timer round = %d, want %d", timers.timers[key].round, old.round)
+	}
+	if timers.active(key, old) {
+		t.Error("turn of a stopped timer should not be active")
+	}
+}
+
+func TestIdiomTimeoutAfterAnswer(t *testing.T) {
+	withIdiomDict(t, NewIdiomDict([]*Idiom{
+		{Word: "画蛇添足"}, {Word: "足智多谋"}, {Word: "谋事在人"}, {Word: "人山人海"},
+	}))
+	p := Processor{store: newTestStore(t), games: NewMemoryGameStore(), timers: newGameTimers()}
+	data := &Message{GuildID: "g1", ChannelID: "c1", Author: &User{ID: "u1", Username: "小明"}}
+	key := gameKeyOf(data)
+
+	user := &IdiomUser{lastMsg: data}
+	user.play(idiomDict.byWord["画蛇添足"], true)
+	if err := p.games.Create(key, user); err != nil {
+		t.Fatal(err)
+	}
+	p.resetIdiomTimer(key)
+	defer p.timers.stop(key)
+	timer := p.timers.timers[key]
+	expired := gameTurn{timer: timer, round: timer.round}
+
+	// 超时回调已触发但还没结束游戏时，玩家答对开始了新的回合
+	p.genAnsweridiom(data, "足智多谋")
+	p.idiomTimeout(key, expired)
+	if _, err := p.games.Get(key); err != nil {
+		t.Fatalf("game ended by a stale timeout: %v", err)
+	}
+
+	// 当前回合超时才结束游戏
+	current := gameTurn{timer: p.timers.timers[key], round: p.timers.timers[key].round}
+	if summary := p.endIdiomGame(key, func(*IdiomUser) error {
+		if !p.timers.active(key, current) {
+			return errStaleTurn
+		}
+		return nil
+	}); summary == "" {
+		t.Error("current turn timeout should end the game")
+	}
+	if _, err := p.games.Get(key); !errors.Is(err, ErrNoGame) {
+		t.Errorf("game should have ended, got %v", err)
+	}
+	if _, ok := p.timers.timers[key]; ok {
+		t.Error("timer should be removed after the game ends")
+	}
+}
+
+func TestStartIdiomGameInProgress(t *testing.T) {
+	withIdiomDict(t, NewIdiomDict([]*Idiom{
+		{Word: "画蛇添足"}, {Word: "足智多谋"}, {Word: "谋事在人"}, {Word: "人山人海"},
+	}))
+	p := Processor{store: newTestStore(t), games: NewMemoryGameStore(), timers: newGameTimers()}
+	data := &Message{GuildID: "g1", ChannelID: "c1", Author: &User{ID: "u1", Username: "小明"}}
+	key := gameKeyOf(data)
+	defer p.timers.stop(key)
+	args, err := ParseCommand("成语接龙").Bind()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.genReplyidiom(context.Background(), data, args)
+	game, err := p.games.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timer := p.timers.timers[key]
+	turn := gameTurn{timer: timer, round: timer.round}
+
+	// 游戏中再次发送“成语接龙”不会丢掉进行中的一局，也不会重新计时
+	if reply := p.genReplyidiom(context.Background(), data, args); !strings.Contains(reply, "你已有进行中的成语接龙") {
+		t.Errorf("restart reply = %q", reply)
+	}
+	again, err := p.games.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.chainText() != game.chainText() {
+		t.Errorf("running game replaced: %s, was %s", again.chainText(), game.chainText())
+	}
+	if !p.timers.active(key, turn) {
+		t.Error("running game's timer was reset")
+	}
+}
@@ -3,20 +3,25 @@ module bot
 go 1.21.5
 
 require (
-	github.com/Mrs4s/MiraiGo v0.0.0-20231010012600-c025459da09e // indirect
-	github.com/RomiChan/protobuf v0.1.1-0.20230204044148-2ed269a2e54d // indirect
-	github.com/RomiChan/syncx v0.0.0-20220404072119-d7ea0ae15a4c // indirect
-	github.com/fumiama/imgsz v0.0.2 // indirect
-	github.com/go-resty/resty/v2 v2.6.0 // indirect
-	github.com/gorilla/websocket v1.4.2 // indirect
-	github.com/mattn/go-sqlite3 v1.14.19 // indirect
-	github.com/pierrec/lz4/v4 v4.1.15 // indirect
-	github.com/pkg/errors v0.9.1 // indirect
-	github.com/tencent-connect/botgo v0.1.6 // indirect
-	github.com/tidwall/gjson v1.14.3 // indirect
+	github.com/fergusstrange/embedded-postgres v1.34.0
+	github.com/go-resty/resty/v2 v2.6.0
+	github.com/gorilla/websocket v1.4.2
+	github.com/hajimehoshi/bitmapfont/v3 v3.2.0
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.19
+	github.com/mozillazg/go-pinyin v0.21.0
+	github.com/tencent-connect/botgo v0.1.6
+	github.com/tidwall/gjson v1.14.3
+	golang.org/x/image v0.20.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/pierrec/lz4/v4 v4.1.21 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
-	golang.org/x/net v0.0.0-20210428140749-89ef3d95e781 // indirect
-	golang.org/x/sync v0.0.0-20220819030929-7fc1605a5dde // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	github.com/xi2/xz v0.0.0-20171230120015-48954b6210f8 // indirect
+	golang.org/x/net v0.25.0 // indirect
+	golang.org/x/text v0.18.0 // indirect
+	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 )
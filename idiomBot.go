@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Difficulty 成语接龙的难度，决定机器人如何选择成语
+type Difficulty int
+
+// 难度
+const (
+	DifficultyNormal    Difficulty = iota // 普通：随机选择
+	DifficultyEasy                        // 简单：优先选择玩家容易接上的成语
+	DifficultyHard                        // 困难：优先选择玩家难以接上的成语
+	DifficultyChallenge                   // 挑战：向后多看一步，设法让玩家无路可走
+)
+
+func (d Difficulty) String() string {
+	switch d {
+	case DifficultyEasy:
+		return "简单"
+	case DifficultyHard:
+		return "困难"
+	case DifficultyChallenge:
+		return "挑战"
+	default:
+		return "普通"
+	}
+}
+
+// difficultyNames 开局时可选的难度名
+var difficultyNames = map[string]Difficulty{
+	"普通": DifficultyNormal,
+	"简单": DifficultyEasy,
+	"困难": DifficultyHard,
+	"挑战": DifficultyChallenge,
+}
+
+// parseGameOptions 解析成语接龙的开局选项：模式名与难度名，顺序不限，省略时为同字模式、普通难度
+func parseGameOptions(options string) (ChainMode, Difficulty, error) {
+	mode, difficulty := ChainSameChar, DifficultyNormal
+	for _, opt := range strings.Fields(options) {
+		if m, ok := chainModeNames[opt]; ok {
+			mode = m
+		} else if d, ok := difficultyNames[opt]; ok {
+			difficulty = d
+		} else {
+			return mode, difficulty, fmt.Errorf("抱歉，没有“%s”这个选项，可选模式：同字、同音、谐音；可选难度：简单、普通、困难、挑战。", opt)
+		}
+	}
+	return mode, difficulty, nil
+}
+
+// after 接上 idiom 之后的游戏状态，不修改 u
+func (u *IdiomUser) after(idiom *Idiom) *IdiomUser {
+	c := u.clone()
+	c.follow(idiom)
+	return c
+}
+
+// replies 接上 idiom 之后对方可以接的成语数量，即成语接龙图中该成语的出度
+func (u *IdiomUser) replies(idiom *Idiom) int {
+	return len(u.after(idiom).next())
+}
+
+// trapScore 挑战难度下一步棋的评分，越小越好：
+// 先看玩家的回答中有多少会让机器人接不下去，再看玩家可选的成语数量
+func (u *IdiomUser) trapScore(idiom *Idiom) (stuck, options int) {
+	state := u.after(idiom)
+	answers := state.next()
+	// 尾字与尾字读音相同的回答，机器人可以接的成语相同，只查一次；查询时共用本局的已用成语，不逐个复制游戏状态
+	type target struct {
+		last   rune
+		pinyin string
+	}
+	replies := map[target][]*Idiom{}
+	for _, answer := range answers {
+		t := target{answer.Last(), answer.LastSyllable()}
+		list, ok := replies[t]
+		if !ok {
+			list = IdiomUser{mode: state.mode, lastward: t.last, lastPinyin: t.pinyin, used: state.used}.next()
+			replies[t] = list
+		}
+		// 回答本身接上之后就算用过了，不能再作为机器人的应答
+		if len(list) == 0 || (len(list) == 1 && list[0] == answer) {
+			stuck++
+		}
+	}
+	return stuck, len(answers)
+}
+
+// botMove 按难度从候选中选择机器人给出的成语，没有可以接的成语时返回 nil
+func botMove(u *IdiomUser, difficulty Difficulty) *Idiom {
+	return chooseIdiom(u, u.next(), difficulty)
+}
+
+// challengeCandidates 挑战难度下至多评估的候选数量
+const challengeCandidates = 30
+
+// chooseIdiom 按难度从候选中选择，评分相同的候选随机取一个
+func chooseIdiom(u *IdiomUser, candidates []*Idiom, difficulty Difficulty) *Idiom {
+	if len(candidates) == 0 || difficulty == DifficultyNormal {
+		return pickIdiom(candidates)
+	}
+	if difficulty == DifficultyChallenge {
+		// 挑战难度要为每个候选推演玩家的全部回答，候选太多时随机抽取一部分评分
+		candidates = sampleIdioms(candidates, challengeCandidates)
+	}
+	var best []*Idiom
+	var bestStuck, bestOptions int
+	for _, idiom := range candidates {
+		var stuck, options int
+		switch difficulty {
+		case DifficultyEasy:
+			options = -u.replies(idiom)
+		case DifficultyHard:
+			options = u.replies(idiom)
+		case DifficultyChallenge:
+			stuck, options = u.trapScore(idiom)
+		}
+		better := len(best) == 0 || stuck < bestStuck || (stuck == bestStuck && options < bestOptions)
+		if better {
+			best, bestStuck, bestOptions = []*Idiom{idiom}, stuck, options
+		} else if stuck == bestStuck && options == bestOptions {
+			best = append(best, idiom)
+		}
+	}
+	return pickIdiom(best)
+}
+
+// startCandidates 开局时随机抽取的候选数量
+const startCandidates = 20
+
+// startIdiom 按难度选择开局成语，开局成语保证玩家有成语可接
+func startIdiom(mode ChainMode, difficulty Difficulty) *Idiom {
+	if difficulty == DifficultyNormal {
+		return randomStartIdiom(mode)
+	}
+	u := &IdiomUser{mode: mode}
+	var candidates []*Idiom
+	for _, i := range rand.Perm(idiomDict.Len()) {
+		idiom := idiomDict.idioms[i]
+		if playable(idiom) && u.replies(idiom) > 0 {
+			candidates = append(candidates, idiom)
+		}
+		if len(candidates) == startCandidates {
+			break
+		}
+	}
+	if len(candidates) == 0 {
+		return randomStartIdiom(mode)
+	}
+	return chooseIdiom(u, candidates, difficulty)
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+// naiveTrapScore 逐个推演玩家回答的评分，用于核对 trapScore
+func naiveTrapScore(u *IdiomUser, idiom *Idiom) (stuck, options int) {
+	state := u.after(idiom)
+	answers := state.next()
+	for _, answer := range answers {
+		if state.replies(answer) == 0 {
+			stuck++
+		}
+	}
+	return stuck, len(answers)
+}
+
+func TestTrapScore(t *testing.T) {
+	withIdiomDict(t, NewIdiomDict([]*Idiom{
+		{Word: "画蛇添足"}, {Word: "足智多谋"}, {Word: "足不出户"}, {Word: "足食足兵"},
+		{Word: "谋事在人"}, {Word: "户枢不蠹"}, {Word: "兵贵神速"}, {Word: "兵不厌诈"},
+		{Word: "人山人海"}, {Word: "人定胜天"}, {Word: "天外有天"}, {Word: "天下为公"},
+	}))
+	for _, mode := range []ChainMode{ChainSameChar, ChainSameTone, ChainSameSound} {
+		u := &IdiomUser{mode: mode}
+		u.follow(idiomDict.byWord["天下为公"])
+		for _, idiom := range idiomDict.idioms {
+			if u.used[idiom.Word] {
+				continue
+			}
+			stuck, options := u.trapScore(idiom)
+			wantStuck, wantOptions := naiveTrapScore(u, idiom)
+			if stuck != wantStuck || options != wantOptions {
+				t.Errorf("%v trapScore(%s) = %d, %d, want %d, %d", mode, idiom.Word, stuck, options, wantStuck, wantOptions)
+			}
+		}
+	}
+}
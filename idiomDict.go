@@ -0,0 +1,290 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// builtinIdioms 内置的精简词库，只有几百个常用成语，供开发测试和单元测试使用，
+// 正式运行请按文档下载完整词库放到 defaultIdiomDict
+//
+//go:embed data/idioms.json
+var builtinIdioms []byte
+
+// Idiom 成语词条
+type Idiom struct {
+	Word        string `json:"word"`
+	Pinyin      string `json:"pinyin"` // 带声调，按字以空格分隔，如 "ān bù dàng chē"
+	Explanation string `json:"explanation,omitempty"`
+	Source      string `json:"source,omitempty"` // 出处
+}
+
+// UnmarshalJSON 兼容 chinese-xinhua 的 idiom.json，其中出处的字段名为 derivation
+func (i *Idiom) UnmarshalJSON(b []byte) error {
+	type idiom Idiom
+	var v struct {
+		idiom
+		Derivation string `json:"derivation"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*i = Idiom(v.idiom)
+	if i.Source == "" {
+		i.Source = v.Derivation
+	}
+	return nil
+}
+
+// First 成语首字
+func (i *Idiom) First() rune {
+	r, _ := utf8.DecodeRuneInString(i.Word)
+	return r
+}
+
+// Last 成语尾字
+func (i *Idiom) Last() rune {
+	r, _ := utf8.DecodeLastRuneInString(i.Word)
+	return r
+}
+
+// Len 成语字数
+func (i *Idiom) Len() int {
+	return utf8.RuneCountInString(i.Word)
+}
+
+// Syllables 每个字的拼音，拼音与字数对不上时返回空
+func (i *Idiom) Syllables() []string {
+	s := strings.Fields(i.Pinyin)
+	if len(s) != i.Len() {
+		return nil
+	}
+	return s
+}
+
+// FirstSyllable 首字拼音
+func (i *Idiom) FirstSyllable() string {
+	if s := i.Syllables(); len(s) > 0 {
+		return s[0]
+	}
+	return ""
+}
+
+// LastSyllable 尾字拼音
+func (i *Idiom) LastSyllable() string {
+	if s := i.Syllables(); len(s) > 0 {
+		return s[len(s)-1]
+	}
+	return ""
+}
+
+// IdiomDict 成语词典，按首字和首字拼音建立索引
+type IdiomDict struct {
+	idioms   []*Idiom
+	byWord   map[string]*Idiom
+	byFirst  map[rune][]*Idiom
+	byPinyin map[string][]*Idiom // 首字拼音，带声调
+	byPlain  map[string][]*Idiom // 首字拼音，不带声调
+}
+
+// idiomDict 全局词典，默认使用内置词库，main 启动时按配置替换
+var idiomDict = mustLoadBuiltinIdioms()
+
+// NewIdiomDict 根据词条建立词典，重复和不足两个字的词条会被忽略
+func NewIdiomDict(list []*Idiom) *IdiomDict {
+	d := &IdiomDict{
+		byWord:   map[string]*Idiom{},
+		byFirst:  map[rune][]*Idiom{},
+		byPinyin: map[string][]*Idiom{},
+		byPlain:  map[string][]*Idiom{},
+	}
+	for _, idiom := range list {
+		idiom.Word = strings.TrimSpace(idiom.Word)
+		idiom.Pinyin = strings.TrimSpace(idiom.Pinyin)
+		if idiom.Len() < 2 || !utf8.ValidString(idiom.Word) {
+			continue
+		}
+		if _, ok := d.byWord[idiom.Word]; ok {
+			continue
+		}
+		// 词库没有拼音或拼音与字数对不上时，按常用读音补全
+		if idiom.Syllables() == nil {
+			idiom.Pinyin = wordPinyin(idiom.Word)
+		}
+		d.idioms = append(d.idioms, idiom)
+		d.byWord[idiom.Word] = idiom
+		d.byFirst[idiom.First()] = append(d.byFirst[idiom.First()], idiom)
+		if py := idiom.FirstSyllable(); py != "" {
+			d.byPinyin[py] = append(d.byPinyin[py], idiom)
+			d.byPlain[stripTone(py)] = append(d.byPlain[stripTone(py)], idiom)
+		}
+	}
+	return d
+}
+
+// defaultIdiomDict 没有配置词库文件时加载的完整词库，即 chinese-xinhua 的 idiom.json
+const defaultIdiomDict = "./data/idiom.json"
+
+// builtinIdiomDict 配置为这个值时使用内置的精简词库，只用于开发测试
+const builtinIdiomDict = "builtin"
+
+// LoadIdiomDict 从文件加载词典，file 为空时加载 defaultIdiomDict，为 builtinIdiomDict 时加载内置的精简词库
+// 完整词库不存在时直接报错，不退回精简词库，以免正式运行时接龙很快无词可接
+func LoadIdiomDict(file string) (*IdiomDict, error) {
+	switch file {
+	case builtinIdiomDict:
+		log.Println("使用内置的精简词库，接龙时可接的成语很少，只适合开发测试")
+		return loadBuiltinIdioms()
+	case "":
+		if _, err := os.Stat(defaultIdiomDict); errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("idiom dict %s not found, download it as described in the docs, or set idiom_dict: %s to use the small builtin dict for testing", defaultIdiomDict, builtinIdiomDict)
+		}
+		file = defaultIdiomDict
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var list []*Idiom
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		err = json.NewDecoder(f).Decode(&list)
+	case ".csv":
+		list, err = parseIdiomCSV(f)
+	default:
+		return nil, fmt.Errorf("unsupported idiom dict format: %s", file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load idiom dict %s: %w", file, err)
+	}
+	d := NewIdiomDict(list)
+	if d.Len() == 0 {
+		return nil, fmt.Errorf("idiom dict %s is empty", file)
+	}
+	return d, nil
+}
+
+func loadBuiltinIdioms() (*IdiomDict, error) {
+	var list []*Idiom
+	if err := json.Unmarshal(builtinIdioms, &list); err != nil {
+		return nil, err
+	}
+	return NewIdiomDict(list), nil
+}
+
+func mustLoadBuiltinIdioms() *IdiomDict {
+	d, err := loadBuiltinIdioms()
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// parseIdiomCSV 解析 csv 词库，首行为表头，需包含 word 列，pinyin、explanation、source 列可选，
+// 出处列也可以沿用 chinese-xinhua 的列名 derivation
+func parseIdiomCSV(r io.Reader) ([]*Idiom, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := cols["word"]; !ok {
+		return nil, errors.New("csv header must contain column \"word\"")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	var list []*Idiom
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		source := field(record, "source")
+		if source == "" {
+			source = field(record, "derivation")
+		}
+		list = append(list, &Idiom{
+			Word:        field(record, "word"),
+			Pinyin:      field(record, "pinyin"),
+			Explanation: field(record, "explanation"),
+			Source:      source,
+		})
+	}
+	return list, nil
+}
+
+// Len 词条数量
+func (d *IdiomDict) Len() int {
+	return len(d.idioms)
+}
+
+// Lookup 精确查找成语
+func (d *IdiomDict) Lookup(word string) (*Idiom, bool) {
+	idiom, ok := d.byWord[word]
+	return idiom, ok
+}
+
+// StartingWith 以指定汉字开头的全部成语
+func (d *IdiomDict) StartingWith(r rune) []*Idiom {
+	return d.byFirst[r]
+}
+
+// StartingWithPinyin 首字拼音（带声调）为指定音节的全部成语
+func (d *IdiomDict) StartingWithPinyin(syllable string) []*Idiom {
+	return d.byPinyin[syllable]
+}
+
+// StartingWithPlainPinyin 首字拼音（忽略声调）为指定音节的全部成语
+func (d *IdiomDict) StartingWithPlainPinyin(syllable string) []*Idiom {
+	return d.byPlain[stripTone(syllable)]
+}
+
+// Random 随机取一个成语
+func (d *IdiomDict) Random() *Idiom {
+	return pickIdiom(d.idioms)
+}
+
+// pickIdiom 从候选中随机取一个，候选为空时返回 nil
+func pickIdiom(list []*Idiom) *Idiom {
+	if len(list) == 0 {
+		return nil
+	}
+	return list[rand.Intn(len(list))]
+}
+
+// sampleIdioms 从候选中随机取至多 n 个，不重复
+func sampleIdioms(list []*Idiom, n int) []*Idiom {
+	if len(list) <= n {
+		return list
+	}
+	picked := make([]*Idiom, 0, n)
+	for _, i := range rand.Perm(len(list))[:n] {
+		picked = append(picked, list[i])
+	}
+	return picked
+}
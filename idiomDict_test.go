@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadIdiomDictSource(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"xinhua.json": `[{"word":"画蛇添足","pinyin":"huà shé tiān zú","derivation":"《战国策·齐策二》"}]`,
+		"source.json": `[{"word":"画蛇添足","source":"《战国策·齐策二》"}]`,
+		"xinhua.csv":  "word,derivation\n画蛇添足,《战国策·齐策二》\n",
+		"source.csv":  "word,pinyin,source\n画蛇添足,huà shé tiān zú,《战国策·齐策二》\n",
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		d, err := LoadIdiomDict(file)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		idiom, ok := d.Lookup("画蛇添足")
+		if !ok {
+			t.Fatalf("%s: idiom not loaded", name)
+		}
+		if idiom.Source != "《战国策·齐策二》" {
+			t.Errorf("%s: source = %q", name, idiom.Source)
+		}
+		if idiom.Pinyin != "huà shé tiān zú" {
+			t.Errorf("%s: pinyin = %q", name, idiom.Pinyin)
+		}
+	}
+}
+
+func TestLoadIdiomDictDefault(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	// 完整词库不存在时报错，明确配置 builtin 时才使用内置词库
+	if _, err := LoadIdiomDict(""); err == nil {
+		t.Error("missing default dict should fail")
+	}
+	d, err := LoadIdiomDict(builtinIdiomDict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Len() != mustLoadBuiltinIdioms().Len() {
+		t.Errorf("builtin dict has %d idioms", d.Len())
+	}
+
+	if err := os.MkdirAll("data", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(defaultIdiomDict, []byte(`[{"word":"画蛇添足"},{"word":"足智多谋"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if d, err = LoadIdiomDict(""); err != nil {
+		t.Fatal(err)
+	}
+	if d.Len() != 2 {
+		t.Errorf("default dict has %d idioms, want 2", d.Len())
+	}
+
+	// 明确配置的文件不存在时报错，不静默退回
+	if _, err := LoadIdiomDict("missing.json"); err == nil {
+		t.Error("missing configured dict should fail")
+	}
+}
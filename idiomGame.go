@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ChainMode 接龙模式
+type ChainMode int
+
+// 接龙模式
+const (
+	ChainSameChar  ChainMode = iota // 同字：首字与上一个成语的尾字相同
+	ChainSameTone                   // 同音：首字与尾字读音相同，声调也相同
+	ChainSameSound                  // 谐音：首字与尾字读音相同，忽略声调
+)
+
+func (m ChainMode) String() string {
+	switch m {
+	case ChainSameTone:
+		return "同音"
+	case ChainSameSound:
+		return "谐音"
+	default:
+		return "同字"
+	}
+}
+
+// chainModeNames 开局时可选的模式名
+var chainModeNames = map[string]ChainMode{
+	"同字":   ChainSameChar,
+	"同音":   ChainSameTone,
+	"谐音":   ChainSameSound,
+	"忽略声调": ChainSameSound,
+}
+
+// follow 记录上一个成语的尾字及其读音，作为下一个成语的接龙目标
+func (u *IdiomUser) follow(idiom *Idiom) {
+	u.lastward = idiom.Last()
+	u.lastPinyin = idiom.LastSyllable()
+	if u.used == nil {
+		u.used = map[string]bool{}
+	}
+	u.used[idiom.Word] = true
+}
+
+// ChainStep 接龙记录中的一步
+type ChainStep struct {
+	Word   string `json:"word"`
+	ByBot  bool   `json:"by_bot,omitempty"` // 是否由机器人给出
+	Player string `json:"player,omitempty"` // 多人接龙中给出该成语的玩家
+}
+
+// play 接上一个成语并记入本局接龙记录，机器人给出成语后开始玩家的回合
+func (u *IdiomUser) play(idiom *Idiom, byBot bool) {
+	u.follow(idiom)
+	u.chain = append(u.chain, ChainStep{Word: idiom.Word, ByBot: byBot})
+	if byBot {
+		u.turnStart = time.Now()
+		u.hinted = false
+		u.hintWord = ""
+		u.hintLevel = 0
+	}
+}
+
+// answer 玩家答对一个成语，按积分规则计分，返回本回合的得分
+func (u *IdiomUser) answer(idiom *Idiom) ScoreBreakdown {
+	turn := scoreTurn(conf.Game.Scoring, idiom, u.streak, time.Since(u.turnStart), u.hinted)
+	u.breakdown.Add(turn)
+	u.score += turn.Total()
+	u.streak++
+	if u.streak > u.bestStreak {
+		u.bestStreak = u.streak
+	}
+	u.play(idiom, false)
+	return turn
+}
+
+// chainText 按顺序以箭头连接本局的全部成语，如 风中之烛→烛照数计→计日程功
+func (u IdiomUser) chainText() string {
+	words := make([]string, 0, len(u.chain))
+	for _, step := range u.chain {
+		words = append(words, step.Word)
+	}
+	return strings.Join(words, "→")
+}
+
+// summary 本局接龙的统计
+func (u IdiomUser) summary() string {
+	answered := 0
+	for _, step := range u.chain {
+		if !step.ByBot {
+			answered++
+		}
+	}
+	return fmt.Sprintf("本局（%s模式，%s难度）共接龙%d个成语，其中你接了%d个。\n%s", u.mode, u.difficulty, len(u.chain), answered, u.breakdown)
+}
+
+// expect 接龙目标的描述，如 "烛"、"烛"（zhú）或同音字
+func (u IdiomUser) expect() string {
+	if u.mode == ChainSameChar || u.lastPinyin == "" {
+		return fmt.Sprintf("\"%c\"", u.lastward)
+	}
+	if u.mode == ChainSameTone {
+		return fmt.Sprintf("\"%c\"（%s）或同音字", u.lastward, u.lastPinyin)
+	}
+	return fmt.Sprintf("\"%c\"（%s）或谐音字", u.lastward, stripTone(u.lastPinyin))
+}
+
+// accepts 成语的首字是否接得上
+func (u IdiomUser) accepts(idiom *Idiom) bool {
+	if idiom.First() == u.lastward {
+		return true
+	}
+	first := idiom.FirstSyllable()
+	if first == "" || u.lastPinyin == "" {
+		return false
+	}
+	switch u.mode {
+	case ChainSameTone:
+		return first == u.lastPinyin
+	case ChainSameSound:
+		return stripTone(first) == stripTone(u.lastPinyin)
+	default:
+		return false
+	}
+}
+
+// next 当前可以接上、本局未用过且可用于接龙的全部成语
+func (u IdiomUser) next() []*Idiom {
+	candidates := idiomDict.StartingWith(u.lastward)
+	if u.lastPinyin != "" {
+		switch u.mode {
+		case ChainSameTone:
+			candidates = append(append([]*Idiom{}, candidates...), idiomDict.StartingWithPinyin(u.lastPinyin)...)
+		case ChainSameSound:
+			candidates = append(append([]*Idiom{}, candidates...), idiomDict.StartingWithPlainPinyin(u.lastPinyin)...)
+		}
+	}
+	var list []*Idiom
+	seen := map[string]bool{}
+	for _, idiom := range candidates {
+		if seen[idiom.Word] || u.used[idiom.Word] || !playable(idiom) {
+			continue
+		}
+		seen[idiom.Word] = true
+		list = append(list, idiom)
+	}
+	return list
+}
+
+// AnswerErrorKind 玩家答案不合规的原因
+type AnswerErrorKind int
+
+// 答案不合规的原因
+const (
+	AnswerNotIdiom   AnswerErrorKind = iota // 不是词库中的成语
+	AnswerBadLength                         // 字数不符合规则
+	AnswerWrongStart                        // 首字没有接上
+	AnswerUsed                              // 本局已经用过
+)
+
+// AnswerError 玩家答案不合规，Error() 的内容可以直接回复给玩家
+type AnswerError struct {
+	Kind   AnswerErrorKind
+	Answer string
+	Expect string // 接龙目标的描述
+}
+
+func (e *AnswerError) Error() string {
+	switch e.Kind {
+	case AnswerNotIdiom:
+		return fmt.Sprintf("“%s”不是词库中的成语，请重新给出一个以%s开头的成语。", e.Answer, e.Expect)
+	case AnswerBadLength:
+		return fmt.Sprintf("“%s”不是四字成语，请给出一个以%s开头的四字成语。", e.Answer, e.Expect)
+	case AnswerWrongStart:
+		return fmt.Sprintf("“%s”没有接上，请给出一个以%s开头的成语。", e.Answer, e.Expect)
+	case AnswerUsed:
+		return fmt.Sprintf("“%s”本局已经用过了，请换一个以%s开头的成语。", e.Answer, e.Expect)
+	default:
+		return fmt.Sprintf("请给出一个以%s开头的成语。", e.Expect)
+	}
+}
+
+// answerTrimSet 玩家答案两端常带的标点和空白
+const answerTrimSet = spaceCharSet + "。，！？.,!?、“”\"'"
+
+// normalizeAnswer 去掉答案两端的空白和标点
+func normalizeAnswer(input string) string {
+	return strings.Trim(input, answerTrimSet)
+}
+
+// playable 成语是否可用于接龙，默认只接受四字成语，可通过配置放开更长的成语
+func playable(idiom *Idiom) bool {
+	n := idiom.Len()
+	return n == 4 || (n > 4 && conf.Game.AllowLongIdioms)
+}
+
+// checkAnswer 校验玩家的答案：必须是词库中可用于接龙的成语（配置放宽时可以是词库之外的汉字），按本局模式接上，且本局未用过
+func checkAnswer(user IdiomUser, input string) (*Idiom, error) {
+	answer := normalizeAnswer(input)
+	idiom, ok := idiomDict.Lookup(answer)
+	if !ok {
+		// 默认严格拒绝词库之外的答案；配置放宽后只要全是汉字就放行，按常用读音判断是否接上
+		if !conf.Game.AcceptUnknownIdioms || !allHan(answer) {
+			return nil, &AnswerError{Kind: AnswerNotIdiom, Answer: answer, Expect: user.expect()}
+		}
+		idiom = &Idiom{Word: answer, Pinyin: wordPinyin(answer)}
+	}
+	if !playable(idiom) {
+		return nil, &AnswerError{Kind: AnswerBadLength, Answer: answer, Expect: user.expect()}
+	}
+	if !user.accepts(idiom) {
+		return nil, &AnswerError{Kind: AnswerWrongStart, Answer: answer, Expect: user.expect()}
+	}
+	if user.used[idiom.Word] {
+		return nil, &AnswerError{Kind: AnswerUsed, Answer: answer, Expect: user.expect()}
+	}
+	return idiom, nil
+}
+
+// randomStartIdiom 随机选一个可用于接龙且能接下去的开局成语，词库中没有这样的成语时随便选一个可用于接龙的
+func randomStartIdiom(mode ChainMode) *Idiom {
+	var fallback *Idiom
+	for _, i := range rand.Perm(idiomDict.Len()) {
+		idiom := idiomDict.idioms[i]
+		if !playable(idiom) {
+			continue
+		}
+		u := IdiomUser{mode: mode}
+		u.follow(idiom)
+		if len(u.next()) > 0 {
+			return idiom
+		}
+		if fallback == nil {
+			fallback = idiom
+		}
+	}
+	return fallback
+}
+
+// looksLikeIdiom 输入去掉两端的标点后是否为可用于接龙的字数且全是汉字
+func looksLikeIdiom(input string) bool {
+	answer := normalizeAnswer(input)
+	return allHan(answer) && playable(&Idiom{Word: answer})
+}
+
+// allHan 字符串是否全是汉字
+func allHan(s string) bool {
+	for _, r := range s {
+		if !unicode.Is(unicode.Han, r) {
+			return false
+		}
+	}
+	return s != ""
+}
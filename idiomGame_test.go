@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// withIdiomDict 在测试期间替换全局词典
+func withIdiomDict(t *testing.T, d *IdiomDict) {
+	t.Helper()
+	old := idiomDict
+	idiomDict = d
+	t.Cleanup(func() { idiomDict = old })
+}
+
+// withAcceptUnknownIdioms 在测试期间设置是否接受词库之外的答案
+func withAcceptUnknownIdioms(t *testing.T, accept bool) {
+	t.Helper()
+	old := conf.Game.AcceptUnknownIdioms
+	conf.Game.AcceptUnknownIdioms = accept
+	t.Cleanup(func() { conf.Game.AcceptUnknownIdioms = old })
+}
+
+func TestCheckAnswer(t *testing.T) {
+	withIdiomDict(t, NewIdiomDict([]*Idiom{
+		{Word: "画蛇添足"}, {Word: "足智多谋"}, {Word: "谋事在人"},
+	}))
+	u := IdiomUser{mode: ChainSameChar}
+	u.follow(idiomDict.byWord["画蛇添足"])
+
+	cases := []struct {
+		accept bool
+		input  string
+		want   string
+		kind   AnswerErrorKind
+	}{
+		{input: "足智多谋。", want: "足智多谋"},
+		// 默认严格拒绝词库之外的答案
+		{input: "足不出户", kind: AnswerNotIdiom},
+		{input: "足足足足", kind: AnswerNotIdiom},
+		{input: "谋事在人", kind: AnswerWrongStart},
+		{input: "足下", kind: AnswerNotIdiom},
+		// 放宽后全是汉字且接得上就放行
+		{accept: true, input: "足不出户", want: "足不出户"},
+		{accept: true, input: "足球比赛abc", kind: AnswerNotIdiom},
+		{accept: true, input: "谋事在人", kind: AnswerWrongStart},
+		{accept: true, input: "足下", kind: AnswerBadLength},
+	}
+	for _, c := range cases {
+		withAcceptUnknownIdioms(t, c.accept)
+		idiom, err := checkAnswer(u, c.input)
+		if c.want != "" {
+			if err != nil || idiom.Word != c.want {
+				t.Errorf("checkAnswer(%q, accept %v) = %v, %v, want %s", c.input, c.accept, idiom, err, c.want)
+			}
+			continue
+		}
+		var answerErr *AnswerError
+		if !errors.As(err, &answerErr) || answerErr.Kind != c.kind {
+			t.Errorf("checkAnswer(%q, accept %v) error = %v, want kind %d", c.input, c.accept, err, c.kind)
+		}
+	}
+}
+
+func TestCheckAnswerBuiltinDict(t *testing.T) {
+	// 默认安装只有内置的精简词库，也不能用叠字刷分
+	withIdiomDict(t, mustLoadBuiltinIdioms())
+	u := IdiomUser{mode: ChainSameChar}
+	u.follow(&Idiom{Word: "谋事在人", Pinyin: "móu shì zài rén"})
+	var answerErr *AnswerError
+	if _, err := checkAnswer(u, "人人人人"); !errors.As(err, &answerErr) || answerErr.Kind != AnswerNotIdiom {
+		t.Errorf("checkAnswer(人人人人) error = %v, want not an idiom", err)
+	}
+}
+
+func TestRandomStartIdiom(t *testing.T) {
+	// 只有“画蛇添足”是可以接下去的四字成语
+	withIdiomDict(t, NewIdiomDict([]*Idiom{
+		{Word: "画蛇添足"}, {Word: "足智多谋"}, {Word: "足下"}, {Word: "一言为定"},
+	}))
+	for i := 0; i < 50; i++ {
+		if got := randomStartIdiom(ChainSameChar); got == nil || got.Word != "画蛇添足" {
+			t.Fatalf("randomStartIdiom = %v, want 画蛇添足", got)
+		}
+	}
+
+	// 没有能接下去的成语时也只选四字成语
+	withIdiomDict(t, NewIdiomDict([]*Idiom{{Word: "足下"}, {Word: "一言为定"}}))
+	if got := randomStartIdiom(ChainSameChar); got == nil || got.Word != "一言为定" {
+		t.Errorf("randomStartIdiom = %v, want 一言为定", got)
+	}
+}
+
+func TestLooksLikeIdiom(t *testing.T) {
+	cases := map[string]bool{
+		"画蛇添足":    true,
+		" 画蛇添足！":  true,
+		"积分榜":     false,
+		"一马当先":    true,
+		"百闻不如一见":  false,
+		"hello":   false,
+		"画蛇添足abc": false,
+		"":        false,
+	}
+	for input, want := range cases {
+		if got := looksLikeIdiom(input); got != want {
+			t.Errorf("looksLikeIdiom(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 渐进提示的层级，每个回合从拼音开始，逐步透露到完整答案
+const (
+	hintPinyin     = iota + 1 // 答案的拼音
+	hintSecondChar            // 答案的前两个字
+	hintFull                  // 完整答案及释义、出处
+)
+
+// ErrHintBudget 本局提示次数已用完
+var ErrHintBudget = errors.New("hint budget exhausted")
+
+// ErrNoHint 已经没有成语可以接，无从提示
+var ErrNoHint = errors.New("no idiom to hint")
+
+// hintTarget 本回合提示的答案，同一回合内的多次提示指向同一个成语
+func (u *IdiomUser) hintTarget() *Idiom {
+	if u.hintWord != "" {
+		if idiom, ok := idiomDict.Lookup(u.hintWord); ok && !u.used[idiom.Word] {
+			return idiom
+		}
+	}
+	// 提示玩家容易接下去的成语
+	idiom := chooseIdiom(u, u.next(), DifficultyEasy)
+	if idiom != nil {
+		u.hintWord = idiom.Word
+		u.hintLevel = 0
+	}
+	return idiom
+}
+
+// nextHint 给出本回合的下一级提示，budget 为每局可用的提示次数，为 0 时不限
+// 已经提示到完整答案后再次提示不再消耗次数
+func (u *IdiomUser) nextHint(budget int) (string, error) {
+	idiom := u.hintTarget()
+	if idiom == nil {
+		return "", ErrNoHint
+	}
+	if u.hintLevel < hintFull {
+		if budget > 0 && u.hintsUsed >= budget {
+			return "", ErrHintBudget
+		}
+		u.hintLevel++
+		u.hintsUsed++
+		u.hinted = true
+	}
+
+	var b strings.Builder
+	switch u.hintLevel {
+	case hintPinyin:
+		fmt.Fprintf(&b, "提示（%d/%d）：答案的拼音是 %s", u.hintLevel, hintFull, idiom.Pinyin)
+	case hintSecondChar:
+		chars := []rune(idiom.Word)
+		fmt.Fprintf(&b, "提示（%d/%d）：答案以“%s”开头，共%d个字", u.hintLevel, hintFull, string(chars[:2]), len(chars))
+	default:
+		fmt.Fprintf(&b, "提示（%d/%d）：答案是“%s”（%s）", hintFull, hintFull, idiom.Word, idiom.Pinyin)
+		if idiom.Explanation != "" {
+			fmt.Fprintf(&b, "\n释义：%s", idiom.Explanation)
+		}
+		if idiom.Source != "" {
+			fmt.Fprintf(&b, "\n出处：%s", idiom.Source)
+		}
+	}
+	if budget > 0 {
+		fmt.Fprintf(&b, "\n本局还可以提示%d次。", budget-u.hintsUsed)
+	}
+	return b.String(), nil
+}
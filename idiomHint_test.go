@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNextHint(t *testing.T) {
+	withIdiomDict(t, NewIdiomDict([]*Idiom{
+		{Word: "画蛇添足", Pinyin: "huà shé tiān zú"},
+		{Word: "足智多谋", Pinyin: "zú zhì duō móu", Explanation: "富有智慧，善于谋划", Source: "元·无名氏《桃园结义》"},
+		{Word: "谋事在人", Pinyin: "móu shì zài rén"},
+	}))
+	type step struct {
+		want string // 提示中应包含的内容
+		err  error
+		used int // 提示后本局已用的次数
+	}
+	cases := []struct {
+		name   string
+		budget int
+		steps  []step
+	}{
+		{name: "progressive", steps: []step{
+			{want: "提示（1/3）：答案的拼音是 zú zhì duō móu", used: 1},
+			{want: "提示（2/3）：答案以“足智”开头，共4个字", used: 2},
+			{want: "提示（3/3）：答案是“足智多谋”（zú zhì duō móu）\n释义：富有智慧，善于谋划\n出处：元·无名氏《桃园结义》", used: 3},
+			// 已经给出完整答案，再次提示不再消耗次数
+			{want: "答案是“足智多谋”", used: 3},
+		}},
+		{name: "budget", budget: 2, steps: []step{
+			{want: "本局还可以提示1次。", used: 1},
+			{want: "本局还可以提示0次。", used: 2},
+			{err: ErrHintBudget, used: 2},
+		}},
+		{name: "budget covers full answer", budget: 3, steps: []step{
+			{used: 1}, {used: 2},
+			{want: "答案是“足智多谋”", used: 3},
+			{want: "答案是“足智多谋”", used: 3},
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			u := IdiomUser{mode: ChainSameChar}
+			u.play(idiomDict.byWord["画蛇添足"], true)
+			for i, s := range c.steps {
+				got, err := u.nextHint(c.budget)
+				if !errors.Is(err, s.err) || !strings.Contains(got, s.want) {
+					t.Errorf("hint %d = %q, %v, want %q, %v", i+1, got, err, s.want, s.err)
+				}
+				if u.hintsUsed != s.used || !u.hinted {
+					t.Errorf("hint %d: used %d, hinted %v, want used %d", i+1, u.hintsUsed, u.hinted, s.used)
+				}
+			}
+		})
+	}
+}
+
+func TestNextHintResetsOnBotMove(t *testing.T) {
+	withIdiomDict(t, NewIdiomDict([]*Idiom{
+		{Word: "画蛇添足", Pinyin: "huà shé tiān zú"},
+		{Word: "足智多谋", Pinyin: "zú zhì duō móu"},
+		{Word: "谋事在人", Pinyin: "móu shì zài rén"},
+		{Word: "人山人海", Pinyin: "rén shān rén hǎi"},
+	}))
+	u := IdiomUser{mode: ChainSameChar}
+	u.play(idiomDict.byWord["画蛇添足"], true)
+	for i := 0; i < 2; i++ {
+		if _, err := u.nextHint(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 玩家答对、机器人接上后，新回合的提示从拼音重新开始，提示次数按整局累计
+	u.answer(idiomDict.byWord["足智多谋"])
+	u.play(idiomDict.byWord["谋事在人"], true)
+	if u.hinted || u.hintWord != "" || u.hintLevel != 0 {
+		t.Fatalf("hint state not reset on the bot's move: %+v", u)
+	}
+	got, err := u.nextHint(0)
+	if err != nil || !strings.Contains(got, "答案的拼音是 rén shān rén hǎi") {
+		t.Errorf("first hint of the new turn = %q, %v", got, err)
+	}
+	if u.hintsUsed != 3 {
+		t.Errorf("hints used = %d, want 3", u.hintsUsed)
+	}
+
+	// 没有成语可接时无从提示
+	u.answer(idiomDict.byWord["人山人海"])
+	if _, err := u.nextHint(0); !errors.Is(err, ErrNoHint) {
+		t.Errorf("hint without answers: %v", err)
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultTurnTimeout 未配置时每回合的作答时限
+const defaultTurnTimeout = 60 * time.Second
+
+// RelayPlayer 多人接龙的参与者
+type RelayPlayer struct {
+	ID   string
+	Name string // 展示用的名字，优先使用频道昵称
+}
+
+// RelayGame 子频道内的多人接龙，子频道内所有人都可以作答，先答对者得分
+type RelayGame struct {
+	IdiomUser // 接龙状态
+
+	ChannelID string
+	Rotate    bool          // 是否在已加入的玩家之间轮流作答
+	Timeout   time.Duration // 每回合的作答时限
+
+	players []*RelayPlayer
+	scores  map[string]*ScoreBreakdown // 玩家ID -> 本局得分
+	turn    int                        // 轮流作答时当前玩家的下标
+	misses  int                        // 连续超时的回合数
+}
+
+// errRelayOver 多人接龙本回合后应当结束
+var errRelayOver = errors.New("relay game is over")
+
+// relayKey 多人接龙的回合计时器，与玩家的游戏共用 gameTimers，UserID 为空以免与玩家的游戏冲突
+func relayKey(channelID string) GameKey {
+	return GameKey{ChannelID: channelID}
+}
+
+// displayName 消息发送者展示用的名字，优先使用频道昵称
+func displayName(data *Message) string {
+	if data.Member != nil && data.Member.Nick != "" {
+		return data.Member.Nick
+	}
+	return data.Author.Username
+}
+
+// player 查找已加入的玩家
+func (g *RelayGame) player(id string) *RelayPlayer {
+	for _, pl := range g.players {
+		if pl.ID == id {
+			return pl
+		}
+	}
+	return nil
+}
+
+// join 加入游戏，已加入时返回 false
+func (g *RelayGame) join(data *Message) bool {
+	if g.player(data.Author.ID) != nil {
+		return false
+	}
+	g.players = append(g.players, &RelayPlayer{
+		ID:   data.Author.ID,
+		Name: displayName(data),
+	})
+	return true
+}
+
+// current 轮流作答时当前应作答的玩家
+func (g *RelayGame) current() *RelayPlayer {
+	if !g.Rotate || len(g.players) == 0 {
+		return nil
+	}
+	return g.players[g.turn%len(g.players)]
+}
+
+// prompt 当前回合的提示语
+func (g *RelayGame) prompt() string {
+	if pl := g.current(); pl != nil {
+		return fmt.Sprintf("请<@%s>在%s内给出一个以%s开头的成语。", pl.ID, formatDuration(g.Timeout), g.expect())
+	}
+	return fmt.Sprintf("请大家在%s内抢答一个以%s开头的成语。", formatDuration(g.Timeout), g.expect())
+}
+
+// parseRelayOptions 解析多人接龙的开局选项：模式名、“轮流”/“抢答”以及每回合时限，顺序不限
+func parseRelayOptions(options string) (*RelayGame, error) {
+	g := &RelayGame{Timeout: turnTimeout(), scores: map[string]*ScoreBreakdown{}}
+	for _, opt := range strings.Fields(options) {
+		if mode, ok := chainModeNames[opt]; ok {
+			g.mode = mode
+			continue
+		}
+		switch opt {
+		case "轮流":
+			g.Rotate = true
+		case "抢答":
+			g.Rotate = false
+		default:
+			d, err := parseDuration(opt)
+			if err != nil {
+				return nil, fmt.Errorf("无法识别的选项“%s”，可选：同字、同音、谐音、轮流、抢答以及每回合时限（如60秒）。", opt)
+			}
+			g.Timeout = d
+		}
+	}
+	return g, nil
+}
+
+// startRelay 在子频道内开始多人接龙，发起者自动加入
+func (p Processor) startRelay(data *Message, args *Args) string {
+	g, err := parseRelayOptions(args.String("选项"))
+	if err != nil {
+		return err.Error()
+	}
+	g.ChannelID = data.ChannelID
+	g.join(data)
+	idiom := randomStartIdiom(g.mode)
+	g.play(idiom, true)
+	g.lastMsg = data
+	if err := p.relays.Create(data.ChannelID, g); errors.Is(err, ErrGameExists) {
+		return "本子频道已经有一局多人接龙正在进行，发送“加入接龙”即可参与。"
+	} else if err != nil {
+		log.Println(err)
+		return "抱歉，多人接龙暂时无法开始，请稍后再试。"
+	}
+	p.resetRelayTimer(g)
+
+	way := "抢答"
+	if g.Rotate {
+		way = "轮流"
+	}
+	var str = `多人接龙开始（%s模式，%s）！
+	我给出的成语是：%s
+	%s
+	其他人@机器人发送“加入接龙”即可参与，先答对者得分。
+	`
+	return fmt.Sprintf(str, g.mode, way, idiom.Word, g.prompt())
+}
+
+// joinRelay 加入子频道内正在进行的多人接龙
+func (p Processor) joinRelay(data *Message) string {
+	var joined bool
+	var players int
+	err := p.relays.Update(data.ChannelID, func(g *RelayGame) error {
+		joined = g.join(data)
+		players = len(g.players)
+		return nil
+	})
+	if errors.Is(err, ErrNoGame) {
+		return "本子频道没有正在进行的多人接龙，发送“多人接龙”开始一局吧！"
+	}
+	if err != nil {
+		log.Println(err)
+		return "抱歉，暂时无法加入，请稍后再试。"
+	}
+	if !joined {
+		return "你已经在本局多人接龙中了。"
+	}
+	return fmt.Sprintf("%s加入了多人接龙，当前共%d名玩家。", displayName(data), players)
+}
+
+// inRelay 子频道内是否有正在进行的多人接龙
+func (p Processor) inRelay(channelID string) bool {
+	_, err := p.relays.Get(channelID)
+	if err != nil && !errors.Is(err, ErrNoGame) {
+		log.Println(err)
+	}
+	return err == nil
+}
+
+// answerRelay 处理多人接龙中的作答，得分与成就在多人接龙的存储锁外保存
+func (p Processor) answerRelay(data *Message, input string) string {
+	var turn *ScoreBreakdown
+	var reply string
+	var over bool
+	err := p.relays.Update(data.ChannelID, func(g *RelayGame) error {
+		turn, over, reply = p.playRelay(g, data, input)
+		return nil
+	})
+	if errors.Is(err, ErrNoGame) {
+		return "本局多人接龙已经结束了。"
+	}
+	if err != nil {
+		log.Println(err)
+		return "抱歉，答案暂时无法提交，请稍后再试。"
+	}
+	if turn == nil {
+		return reply
+	}
+
+	achieved := p.achieve(context.Background(), data.Author.ID, displayName(data), GameEvent{Kind: EventAnswer, Rare: turn.Rare > 0})
+	if over {
+		// 已无成语可接，也不会再有人答对，结束游戏；同时被发起者结束时由结束的一方结算
+		g, err := p.relays.Finish(data.ChannelID, nil)
+		if err != nil {
+			if !errors.Is(err, ErrNoGame) {
+				log.Println(err)
+			}
+			return reply + achieved
+		}
+		return reply + p.settleRelay(g.end()) + achieved
+	}
+	return fmt.Sprintf("%s答对了，得%d分！%s\n%s", displayName(data), turn.Total(), achieved, reply)
+}
+
+// playRelay 校验作答并接上成语，在多人接龙的存储锁内调用。答错时只返回回复；答对时返回本回合得分，
+// 以及下一回合的提示，无成语可接时停止计时，改为返回游戏结束的提示，由调用方结束游戏
+func (p Processor) playRelay(g *RelayGame, data *Message, input string) (*ScoreBreakdown, bool, string) {
+	if pl := g.current(); pl != nil && pl.ID != data.Author.ID {
+		if g.player(data.Author.ID) == nil {
+			return nil, false, fmt.Sprintf("本局为轮流作答，请先发送“加入接龙”，当前轮到%s。", pl.Name)
+		}
+		return nil, false, fmt.Sprintf("还没轮到你哦，当前轮到%s。", pl.Name)
+	}
+	idiom, err := checkAnswer(g.IdiomUser, input)
+	if err != nil {
+		return nil, false, err.Error()
+	}
+	// 抢答模式下作答即视为加入
+	g.join(data)
+	// 多人接龙中回合不属于某一名玩家，不计连击与提示
+	turn := scoreTurn(conf.Game.Scoring, idiom, 0, time.Since(g.turnStart), false)
+	if g.scores[data.Author.ID] == nil {
+		g.scores[data.Author.ID] = &ScoreBreakdown{}
+	}
+	g.scores[data.Author.ID].Add(turn)
+	g.chain = append(g.chain, ChainStep{Word: idiom.Word, Player: displayName(data)})
+	g.follow(idiom)
+	g.misses = 0
+	g.turn++
+	g.lastMsg = data
+
+	if len(g.next()) == 0 {
+		p.timers.stop(relayKey(g.ChannelID))
+		return &turn, true, fmt.Sprintf("%s答对了！词库中已没有以%s开头且本局未用过的成语，游戏结束！\n", displayName(data), g.expect())
+	}
+	g.turnStart = time.Now()
+	p.resetRelayTimer(g)
+	return &turn, false, g.prompt()
+}
+
+// resetRelayTimer 多人接龙的新回合开始计时
+func (p Processor) resetRelayTimer(g *RelayGame) {
+	channelID := g.ChannelID
+	p.timers.reset(relayKey(channelID), g.Timeout, conf.Game.RemindBefore,
+		func(turn gameTurn) { p.relayRemind(channelID, turn) },
+		func(turn gameTurn) { p.relayTimeout(channelID, turn) },
+	)
+}
+
+// restoreRelays 重启后为进行中的多人接龙重新计时
+func (p Processor) restoreRelays() {
+	games, err := p.relays.All()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, g := range games {
+		p.resetRelayTimer(g)
+	}
+	if len(games) > 0 {
+		log.Printf("%d relay games restored\n", len(games))
+	}
+}
+
+// relayRemind 回合即将超时时提醒
+func (p Processor) relayRemind(channelID string, turn gameTurn) {
+	if !p.timers.active(relayKey(channelID), turn) {
+		return
+	}
+	g, err := p.relays.Get(channelID)
+	if err != nil {
+		return
+	}
+	content := fmt.Sprintf("还剩%s！", formatDuration(conf.Game.RemindBefore))
+	if pl := g.current(); pl != nil {
+		content += fmt.Sprintf("请<@%s>给出一个以%s开头的成语。", pl.ID, g.expect())
+	} else {
+		content += fmt.Sprintf("请大家抢答一个以%s开头的成语。", g.expect())
+	}
+	p.notify(g.lastMsg, content)
+}
+
+// relayTimeout 回合超时：抢答模式直接结束，轮流模式跳过当前玩家，所有人都连续超时后结束
+func (p Processor) relayTimeout(channelID string, turn gameTurn) {
+	key := relayKey(channelID)
+	var content string
+	var data *Message
+	err := p.relays.Update(channelID, func(g *RelayGame) error {
+		// 超时回调触发后仍可能有人抢先答对，在存储锁内再确认一次回合
+		if !p.timers.active(key, turn) {
+			return errStaleTurn
+		}
+		pl := g.current()
+		if pl == nil || g.misses+1 >= len(g.players) {
+			return errRelayOver
+		}
+		g.misses++
+		g.turn++
+		g.turnStart = time.Now()
+		p.resetRelayTimer(g)
+		content = fmt.Sprintf("%s超时未作答，跳过。\n%s", pl.Name, g.prompt())
+		data = g.lastMsg
+		return nil
+	})
+	if errors.Is(err, errRelayOver) {
+		var g *RelayGame
+		g, err = p.relays.Finish(channelID, func(*RelayGame) error {
+			if !p.timers.active(key, turn) {
+				return errStaleTurn
+			}
+			return nil
+		})
+		if err == nil {
+			p.timers.stop(key)
+			content = fmt.Sprintf("%s内无人接上，游戏结束！\n%s", formatDuration(g.Timeout), p.settleRelay(g.end()))
+			data = g.lastMsg
+		}
+	}
+	if err != nil {
+		if !errors.Is(err, errStaleTurn) && !errors.Is(err, ErrNoGame) {
+			log.Println(err)
+		}
+		return
+	}
+	p.notify(data, content)
+}
+
+// stopRelay 发起者提前结束子频道内的多人接龙
+func (p Processor) stopRelay(data *Message) (string, bool) {
+	var starter string
+	g, err := p.relays.Finish(data.ChannelID, func(g *RelayGame) error {
+		if g.players[0].ID != data.Author.ID {
+			starter = g.players[0].Name
+			return errRelayNotStarter
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, ErrNoGame):
+		return "", false
+	case errors.Is(err, errRelayNotStarter):
+		return fmt.Sprintf("只有发起者%s可以结束本局多人接龙。", starter), true
+	case err != nil:
+		log.Println(err)
+		return "抱歉，多人接龙暂时无法结束，请稍后再试。", true
+	}
+	p.timers.stop(relayKey(data.ChannelID))
+	return fmt.Sprintf("%s结束了多人接龙！\n%s", displayName(data), p.settleRelay(g.end())), true
+}
+
+// errRelayNotStarter 只有发起者可以结束多人接龙
+var errRelayNotStarter = errors.New("only the starter can stop the relay game")
+
+// relayResult 结束的一局多人接龙，由 settleRelay 保存
+type relayResult struct {
+	GuildID   string
+	ChannelID string
+	Chain     []ChainStep
+	ChainText string
+	Players   []*RelayPlayer // 全部参与者，按加入的顺序
+	Ranked    []*RelayPlayer // 得分的玩家，按得分从高到低
+	Scores    map[string]*ScoreBreakdown
+}
+
+// end 已从存储中结束的多人接龙的结果
+func (g *RelayGame) end() *relayResult {
+	players := make([]*RelayPlayer, 0, len(g.players))
+	for _, pl := range g.players {
+		if g.scores[pl.ID] != nil {
+			players = append(players, pl)
+		}
+	}
+	sort.SliceStable(players, func(i, j int) bool {
+		return g.scores[players[i].ID].Total() > g.scores[players[j].ID].Total()
+	})
+	return &relayResult{
+		GuildID:   g.lastMsg.GuildID,
+		ChannelID: g.ChannelID,
+		Chain:     g.chain,
+		ChainText: g.chainText(),
+		Players:   g.players,
+		Ranked:    players,
+		Scores:    g.scores,
+	}
+}
+
+// settleRelay 保存结束的多人接龙中每名玩家的得分与成就，并生成本局排名
+func (p Processor) settleRelay(r *relayResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "本局共接龙%d个成语：%s", len(r.Chain), r.ChainText)
+	if len(r.Ranked) == 0 {
+		b.WriteString("\n本局没有玩家得分。")
+	}
+	ctx := context.Background()
+	var achieved strings.Builder
+	for _, pl := range r.Players {
+		// 多人接龙不计连击
+		if err := p.store.RecordGame(ctx, pl.ID, 0); err != nil {
+			log.Println(err)
+		}
+		achieved.WriteString(p.achieve(ctx, pl.ID, pl.Name, GameEvent{Kind: EventGameOver}))
+	}
+	// 至少两人参加时，得分最高的玩家（并列时都算）获得第一名
+	for _, pl := range r.Ranked {
+		if len(r.Players) < 2 || r.Scores[pl.ID].Total() < r.Scores[r.Ranked[0].ID].Total() {
+			break
+		}
+		achieved.WriteString(p.achieve(ctx, pl.ID, pl.Name, GameEvent{Kind: EventRelayWin}))
+	}
+	for i, pl := range r.Ranked {
+		settled := p.settleScore(ctx, ScoreRecord{
+			UserID:    pl.ID,
+			GuildID:   r.GuildID,
+			ChannelID: r.ChannelID,
+			Name:      pl.Name,
+			CreatedAt: time.Now(),
+		}, *r.Scores[pl.ID])
+		fmt.Fprintf(&b, "\n第%d名  %s  %d分", i+1, pl.Name, settled.Total())
+		if settled.Capped > 0 {
+			fmt.Fprintf(&b, "（超出每日上限未计入%d分）", settled.Capped)
+		}
+	}
+	b.WriteString(achieved.String())
+	return b.String()
+}
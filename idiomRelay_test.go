@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockCheckStore 写数据库时检查多人接龙的存储锁是否已释放
+type lockCheckStore struct {
+	Storage
+	relays *MemoryRelayStore
+	t      *testing.T
+}
+
+func (s lockCheckStore) checkUnlocked(op string) {
+	if !s.relays.mu.TryLock() {
+		s.t.Errorf("%s called while holding the relay store lock", op)
+		return
+	}
+	s.relays.mu.Unlock()
+}
+
+func (s lockCheckStore) RecordScore(ctx context.Context, r ScoreRecord) error {
+	s.checkUnlocked("RecordScore")
+	return s.Storage.RecordScore(ctx, r)
+}
+
+func (s lockCheckStore) RecordCappedScore(ctx context.Context, r ScoreRecord, since time.Time, limit int) (int, error) {
+	s.checkUnlocked("RecordCappedScore")
+	return s.Storage.RecordCappedScore(ctx, r, since, limit)
+}
+
+func (s lockCheckStore) RecordGame(ctx context.Context, userID string, streak int) error {
+	s.checkUnlocked("RecordGame")
+	return s.Storage.RecordGame(ctx, userID, streak)
+}
+
+func (s lockCheckStore) UnlockAchievement(ctx context.Context, userID, achievementID string, at time.Time) (bool, error) {
+	s.checkUnlocked("UnlockAchievement")
+	return s.Storage.UnlockAchievement(ctx, userID, achievementID, at)
+}
+
+// recordAPI 记下发送的消息，其他接口未实现
+type recordAPI struct {
+	OpenAPI
+	mu       sync.Mutex
+	messages []string
+}
+
+func (a *recordAPI) PostMessage(ctx context.Context, channelID string, msg *MessageToCreate) (*Message, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.messages = append(a.messages, msg.Content)
+	return &Message{}, nil
+}
+
+// startTestRelay 在子频道 c1 开始一局多人接龙
+func startTestRelay(t *testing.T, p Processor, data *Message, options string) {
+	t.Helper()
+	args, err := ParseCommand(strings.TrimSpace("多人接龙 " + options)).Bind()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply := p.startRelay(data, args); !strings.Contains(reply, "多人接龙开始") {
+		t.Fatalf("relay not started: %s", reply)
+	}
+	t.Cleanup(func() { p.timers.stop(relayKey(data.ChannelID)) })
+}
+
+func TestAnswerRelaySettlesOutsideLock(t *testing.T) {
+	withIdiomDict(t, NewIdiomDict([]*Idiom{{Word: "画蛇添足"}, {Word: "足智多谋"}}))
+	relays := NewMemoryRelayStore()
+	p := Processor{store: lockCheckStore{Storage: newTestStore(t), relays: relays, t: t}, relays: relays, timers: newGameTimers()}
+
+	data := &Message{ChannelID: "c1", GuildID: "g1", Author: &User{ID: "u1", Username: "小明"}}
+	startTestRelay(t, p, data, "")
+
+	// 答出最后一个可接的成语，游戏结束并保存得分
+	reply := p.answerRelay(data, "足智多谋")
+	if !strings.Contains(reply, "游戏结束") || !strings.Contains(reply, "第1名  小明") {
+		t.Errorf("unexpected reply: %s", reply)
+	}
+	if p.inRelay(data.ChannelID) {
+		t.Error("relay game should have ended")
+	}
+	if _, ok := p.timers.timers[relayKey(data.ChannelID)]; ok {
+		t.Error("relay timer should be removed after the game ends")
+	}
+	score, err := p.store.QueryUserScore(context.Background(), "u1", "g1", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score == 0 {
+		t.Error("relay score was not saved")
+	}
+}
+
+func TestRelayRestoredAfterRestart(t *testing.T) {
+	withIdiomDict(t, NewIdiomDict([]*Idiom{{Word: "画蛇添足"}, {Word: "足智多谋"}, {Word: "谋事在人"}}))
+	store := newTestStore(t)
+	p := Processor{store: store, relays: store.RelayStore(), timers: newGameTimers()}
+	data := &Message{ChannelID: "c1", GuildID: "g1", Author: &User{ID: "u1", Username: "小明"}}
+	startTestRelay(t, p, data, "轮流 30秒")
+	// 固定开局的成语，保证答对后还能接下去
+	if err := p.relays.Update("c1", func(g *RelayGame) error {
+		g.IdiomUser = IdiomUser{mode: g.mode, lastMsg: g.lastMsg}
+		g.play(idiomDict.byWord["画蛇添足"], true)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	p.joinRelay(&Message{ChannelID: "c1", GuildID: "g1", Author: &User{ID: "u2", Username: "小红"}})
+	if reply := p.answerRelay(data, "足智多谋"); !strings.Contains(reply, "小明答对了") {
+		t.Fatalf("answer rejected: %s", reply)
+	}
+	p.timers.stop(relayKey("c1"))
+
+	// 重启后从数据库中恢复进行中的多人接龙，轮到小红作答
+	restarted := Processor{store: store, relays: store.RelayStore(), timers: newGameTimers()}
+	restarted.restoreRelays()
+	defer restarted.timers.stop(relayKey("c1"))
+	if _, ok := restarted.timers.timers[relayKey("c1")]; !ok {
+		t.Error("relay timer was not restored")
+	}
+	g, err := restarted.relays.Get("c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.Rotate || g.Timeout != 30*time.Second || len(g.players) != 2 || g.current().ID != "u2" || g.scores["u1"] == nil {
+		t.Errorf("restored relay = %+v", g)
+	}
+	if reply := restarted.answerRelay(data, "谋事在人"); !strings.Contains(reply, "还没轮到你") {
+		t.Errorf("answer out of turn: %s", reply)
+	}
+}
+
+func TestRelayTimeout(t *testing.T) {
+	withIdiomDict(t, NewIdiomDict([]*Idiom{{Word: "画蛇添足"}, {Word: "足智多谋"}, {Word: "谋事在人"}}))
+	api := &recordAPI{}
+	p := Processor{api: api, store: newTestStore(t), relays: NewMemoryRelayStore(), timers: newGameTimers()}
+	data := &Message{ChannelID: "c1", GuildID: "g1", Author: &User{ID: "u1", Username: "小明"}}
+	startTestRelay(t, p, data, "轮流")
+	p.joinRelay(&Message{ChannelID: "c1", GuildID: "g1", Author: &User{ID: "u2", Username: "小红"}})
+	key := relayKey("c1")
+	turnOf := func() gameTurn {
+		timer := p.timers.timers[key]
+		return gameTurn{timer: timer, round: timer.round}
+	}
+
+	// 过期的回合不处理，当前回合超时跳过小明
+	stale := turnOf()
+	p.timers.reset(key, time.Hour, 0, func(gameTurn) {}, func(gameTurn) {})
+	p.relayTimeout("c1", stale)
+	if g, _ := p.relays.Get("c1"); g.misses != 0 {
+		t.Fatalf("stale timeout was handled: %+v", g)
+	}
+	p.relayTimeout("c1", turnOf())
+	if g, _ := p.relays.Get("c1"); g.current().ID != "u2" || g.misses != 1 {
+		t.Fatalf("timeout did not skip the player: %+v", g)
+	}
+	if len(api.messages) != 1 || !strings.Contains(api.messages[0], "小明超时未作答") {
+		t.Errorf("skip notice = %v", api.messages)
+	}
+	// 所有人都连续超时后结束
+	p.relayTimeout("c1", turnOf())
+	if p.inRelay("c1") {
+		t.Error("relay should end after everyone timed out")
+	}
+	if len(api.messages) != 2 || !strings.Contains(api.messages[1], "游戏结束") {
+		t.Errorf("end notice = %v", api.messages)
+	}
+}
@@ -31,6 +31,10 @@ type WebsocketAPI interface {
 type MessageAPI interface {
 	Message(ctx context.Context, channelID string, messageID string) (*Message, error)
 	PostMessage(ctx context.Context, channelID string, msg *MessageToCreate) (*Message, error)
+	// PostMessageImage 上传内存中的图片并发送消息
+	PostMessageImage(ctx context.Context, channelID string, msg *MessageToCreate, name string, image []byte) (*Message, error)
+	// PostMessageFile 上传本地图片文件并发送消息
+	PostMessageFile(ctx context.Context, channelID string, msg *MessageToCreate, file string) (*Message, error)
 	PostDirectMessage(ctx context.Context, dm *DirectMessage, msg *MessageToCreate) (*Message, error)
 }
 
@@ -40,4 +44,6 @@ type DirectMessageAPI interface {
 	CreateDirectMessage(ctx context.Context, dm *DirectMessageToCreate) (*DirectMessage, error)
 	// PostDirectMessage 在私信频道内发消息
 	PostDirectMessage(ctx context.Context, dm *DirectMessage, msg *MessageToCreate) (*Message, error)
+	// PostDirectMessageImage 在私信频道内上传图片并发送消息
+	PostDirectMessageImage(ctx context.Context, dm *DirectMessage, msg *MessageToCreate, name string, image []byte) (*Message, error)
 }
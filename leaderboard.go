@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// BoardPeriod 积分榜的统计区间
+type BoardPeriod int
+
+// 积分榜的统计区间
+const (
+	PeriodToday      BoardPeriod = iota // 今日
+	PeriodWeek                          // 本周，从周一开始
+	PeriodMonth                         // 本月
+	PeriodSeason                        // 本赛季
+	PeriodLastSeason                    // 上赛季
+	PeriodAll                           // 总榜
+)
+
+func (p BoardPeriod) String() string {
+	switch p {
+	case PeriodWeek:
+		return "本周"
+	case PeriodMonth:
+		return "本月"
+	case PeriodSeason:
+		return "本赛季"
+	case PeriodLastSeason:
+		return "上赛季"
+	case PeriodAll:
+		return "总榜"
+	default:
+		return "今日"
+	}
+}
+
+// boardPeriodNames 积分榜指令可选的区间名
+var boardPeriodNames = map[string]BoardPeriod{
+	"今日":  PeriodToday,
+	"今天":  PeriodToday,
+	"本周":  PeriodWeek,
+	"本月":  PeriodMonth,
+	"本赛季": PeriodSeason,
+	"赛季":  PeriodSeason,
+	"上赛季": PeriodLastSeason,
+	"总榜":  PeriodAll,
+	"全部":  PeriodAll,
+}
+
+// BoardScope 积分榜的统计范围，GuildID 为空表示跨频道的全服榜，ChannelID 为空表示整个频道
+type BoardScope struct {
+	GuildID   string
+	ChannelID string
+}
+
+// scopeOf 消息所在频道的统计范围
+func scopeOf(data *Message) BoardScope {
+	return BoardScope{GuildID: data.GuildID}
+}
+
+// Global 是否为全服榜
+func (s BoardScope) Global() bool {
+	return s.GuildID == ""
+}
+
+// String 统计范围的名称，用作积分榜标题的前缀
+func (s BoardScope) String() string {
+	switch {
+	case s.Global():
+		return "全服"
+	case s.ChannelID != "":
+		return "本子频道"
+	default:
+		return ""
+	}
+}
+
+// parseBoardScope 解析积分榜指令的统计范围：省略时为本频道，“本子频道”只统计当前子频道，
+// “全服”统计机器人所在的全部频道，需要在配置中开启 global_board
+func parseBoardScope(data *Message, name string) (BoardScope, error) {
+	scope := scopeOf(data)
+	switch name {
+	case "", "本频道":
+		return scope, nil
+	case "本子频道":
+		scope.ChannelID = data.ChannelID
+		return scope, nil
+	case "全服":
+		if !conf.GlobalBoard {
+			return scope, fmt.Errorf("全服积分榜没有开启。")
+		}
+		return BoardScope{}, nil
+	default:
+		return scope, fmt.Errorf("可选统计范围：本频道、本子频道、全服，如：积分榜 本周 本子频道")
+	}
+}
+
+// myScorePeriods 我的积分中依次展示的区间
+var myScorePeriods = []BoardPeriod{PeriodToday, PeriodWeek, PeriodMonth, PeriodSeason, PeriodAll}
+
+// allSeasons 频道的全部赛季，第一次开启赛季之前的积分算作第1赛季
+func (p Processor) allSeasons(ctx context.Context, guildID string) ([]Season, error) {
+	seasons, err := p.store.QuerySeasons(ctx, guildID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]Season{{Name: "第1赛季"}}, seasons...), nil
+}
+
+// boardRange 统计区间的起止时间和名称，until 为零值表示至今
+// 赛季由各频道自行开启，全服榜不支持按赛季统计
+func (p Processor) boardRange(ctx context.Context, scope BoardScope, period BoardPeriod, now time.Time) (since, until time.Time, name string, err error) {
+	today := startOfDay(now)
+	switch period {
+	case PeriodToday:
+		return today, time.Time{}, "今日", nil
+	case PeriodWeek:
+		return today.AddDate(0, 0, -(int(now.Weekday())+6)%7), time.Time{}, "本周", nil
+	case PeriodMonth:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), time.Time{}, "本月", nil
+	case PeriodSeason, PeriodLastSeason:
+		if scope.Global() {
+			return since, until, "", fmt.Errorf("全服积分榜不支持按赛季查看")
+		}
+		seasons, err := p.allSeasons(ctx, scope.GuildID)
+		if err != nil {
+			return since, until, "", err
+		}
+		n := len(seasons)
+		if period == PeriodSeason {
+			return seasons[n-1].StartedAt, time.Time{}, seasons[n-1].Name, nil
+		}
+		if n < 2 {
+			return since, until, "", fmt.Errorf("还没有上赛季")
+		}
+		return seasons[n-2].StartedAt, seasons[n-1].StartedAt, seasons[n-2].Name, nil
+	default:
+		return time.Time{}, time.Time{}, "总", nil
+	}
+}
+
+// boardTop 积分榜展示的名次数，之后的玩家只展示查询者自己
+const boardTop = 20
+
+// boardView 查询好的一份积分榜，可以渲染为 embed 卡片或 markdown
+type boardView struct {
+	Title string
+	Note  string             // 没有玩家上榜或查询出错时的说明
+	Board []LeaderboardEntry // 前 boardTop 名
+	Mine  *LeaderboardEntry  // 查询者排在 boardTop 名之后时的名次
+	Total int                // 上榜的玩家总数
+}
+
+// leaderboardView 查询指定范围、区间的积分榜，userID 为查询者，排在榜单之外时单独展示其名次
+func (p Processor) leaderboardView(ctx context.Context, scope BoardScope, period BoardPeriod, userID string) boardView {
+	since, until, name, err := p.boardRange(ctx, scope, period, time.Now())
+	if err != nil {
+		return boardView{Title: "成语接龙积分榜", Note: err.Error()}
+	}
+	view := boardView{Title: fmt.Sprintf("成语接龙%s%s积分榜", scope, name)}
+	board, err := p.store.QueryLeaderboard(ctx, scope, since, until)
+	if err != nil {
+		log.Println(err)
+		view.Note = "积分榜暂时无法查询，请稍后再试。"
+		return view
+	}
+	if len(board) == 0 {
+		view.Note = fmt.Sprintf("%s还没有玩家上榜，快来玩成语接龙吧！", period)
+		return view
+	}
+	view.Total = len(board)
+	if len(board) > boardTop {
+		for i := boardTop; i < len(board); i++ {
+			if board[i].UserID == userID {
+				view.Mine = &board[i]
+				break
+			}
+		}
+		board = board[:boardTop]
+	}
+	view.Board = board
+	return view
+}
+
+// more 榜单之外的说明，没有省略玩家时为空
+func (v boardView) more() string {
+	if v.Total <= len(v.Board) {
+		return ""
+	}
+	return fmt.Sprintf("共%d名玩家上榜，仅展示前%d名", v.Total, len(v.Board))
+}
+
+// Embed 以 embed 卡片展示积分榜，每名玩家一行
+func (v boardView) Embed() *Embed {
+	embed := NewEmbed(v.Title, v.Title)
+	if v.Note != "" {
+		return embed.WithDescription(v.Note)
+	}
+	for _, e := range v.Board {
+		embed.AddField(fmt.Sprintf("第%d名  %s  %d分", e.Rank, e.Name, e.Score), "")
+	}
+	if v.Mine != nil {
+		embed.AddField(fmt.Sprintf("你：第%d名  %s  %d分", v.Mine.Rank, v.Mine.Name, v.Mine.Score), "")
+	}
+	if more := v.more(); more != "" {
+		embed.AddField(more, "")
+	}
+	return embed
+}
+
+// Markdown 以 markdown 列表展示积分榜
+func (v boardView) Markdown() *Markdown {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", v.Title)
+	if v.Note != "" {
+		b.WriteString(v.Note)
+	}
+	for _, e := range v.Board {
+		fmt.Fprintf(&b, "- 第%d名 **%s** %d分\n", e.Rank, EscapeMarkdown(e.Name), e.Score)
+	}
+	if v.Mine != nil {
+		fmt.Fprintf(&b, "\n你：第%d名 **%s** %d分\n", v.Mine.Rank, EscapeMarkdown(v.Mine.Name), v.Mine.Score)
+	}
+	if more := v.more(); more != "" {
+		fmt.Fprintf(&b, "\n%s\n", more)
+	}
+	return NewMarkdown(b.String())
+}
+
+// boardKeyboard 积分榜下方切换区间的按钮
+func boardKeyboard() *MessageKeyboard {
+	return NewKeyboard().
+		AddRow(CommandButton("今日", "积分榜 今日", true), CommandButton("本周", "积分榜 本周", true), CommandButton("总榜", "积分榜 总榜", true)).
+		AddRow(CommandButton("我的积分", "我的积分", true), CommandButton("再来一局", "成语接龙", true))
+}
+
+// setLeaderboard 按是否开通 markdown 把积分榜填入消息，data 为查询积分榜的消息
+func (p Processor) setLeaderboard(ctx context.Context, toCreate *MessageToCreate, data *Message, scope BoardScope, period BoardPeriod) {
+	view := p.leaderboardView(ctx, scope, period, data.Author.ID)
+	toCreate.Content = ""
+	if conf.Markdown {
+		toCreate.Markdown, toCreate.Keyboard = view.Markdown(), boardKeyboard()
+	} else {
+		toCreate.Embed = view.Embed()
+	}
+}
+
+// myScore 玩家在本频道各区间的积分、名次与个人最佳
+func (p Processor) myScore(ctx context.Context, data *Message) string {
+	now := time.Now()
+	scope := scopeOf(data)
+	var b strings.Builder
+	fmt.Fprintf(&b, "你好，%s的积分：", displayName(data))
+	for _, period := range myScorePeriods {
+		since, until, name, err := p.boardRange(ctx, scope, period, now)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		board, err := p.store.QueryLeaderboard(ctx, scope, since, until)
+		if err != nil {
+			log.Println(err)
+			return "积分暂时无法查询，请稍后再试。"
+		}
+		line := "0分（未上榜）"
+		for _, e := range board {
+			if e.UserID == data.Author.ID {
+				line = fmt.Sprintf("%d分（第%d名，共%d人）", e.Score, e.Rank, len(board))
+				break
+			}
+		}
+		if period != PeriodSeason {
+			name = period.String()
+		}
+		fmt.Fprintf(&b, "\n%s：%s", name, line)
+	}
+
+	best, err := p.store.QueryPersonalBest(ctx, data.Author.ID, data.GuildID)
+	if err != nil {
+		log.Println(err)
+		return b.String()
+	}
+	if best.Game > 0 {
+		fmt.Fprintf(&b, "\n单局最高：%d分（%s）", best.Game, best.GameAt.Format("2006-01-02"))
+		fmt.Fprintf(&b, "\n单日最高：%d分（%s）", best.Day, best.DayDate)
+	}
+	return b.String()
+}
+
+// newSeason 管理员为本频道开启新赛季，之前的积分全部保留，可以通过“积分榜 上赛季”查看
+func (p Processor) newSeason(ctx context.Context, data *Message, args *Args) string {
+	if !isAdmin(data) {
+		return "只有管理员可以开启新赛季。"
+	}
+	seasons, err := p.allSeasons(ctx, data.GuildID)
+	if err != nil {
+		log.Println(err)
+		return "赛季暂时无法开启，请稍后再试。"
+	}
+	name := args.String("名称")
+	if name == "" {
+		name = fmt.Sprintf("第%d赛季", len(seasons)+1)
+	}
+	if _, err := p.store.StartSeason(ctx, data.GuildID, name, time.Now()); err != nil {
+		log.Println(err)
+		return "赛季暂时无法开启，请稍后再试。"
+	}
+	return fmt.Sprintf("%s开始了！%s的积分已归档，发送“积分榜 上赛季”可以查看。", name, seasons[len(seasons)-1].Name)
+}
+
+// exportMessageSize 私信发送积分榜 CSV 的消息的最大字节数
+const exportMessageSize = 2000
+
+// exportLeaderboard 管理员导出本频道的积分榜，以 CSV 文本私信发送给管理员
+func (p Processor) exportLeaderboard(ctx context.Context, data *Message, args *Args) string {
+	if !isAdmin(data) {
+		return "只有管理员可以导出积分榜。"
+	}
+	period, ok := boardPeriodNames[args.String("范围")]
+	if !ok {
+		return "可选范围：今日、本周、本月、本赛季、上赛季、总榜，如：导出积分榜 上赛季"
+	}
+	scope := scopeOf(data)
+	since, until, name, err := p.boardRange(ctx, scope, period, time.Now())
+	if err != nil {
+		return err.Error()
+	}
+	board, err := p.store.QueryLeaderboard(ctx, scope, since, until)
+	if err != nil {
+		log.Println(err)
+		return "积分榜暂时无法导出，请稍后再试。"
+	}
+	if len(board) == 0 {
+		return fmt.Sprintf("%s还没有玩家上榜。", period)
+	}
+
+	// 私信只能发送文字和图片，CSV 以文本发送，复制后保存为 .csv 文件即可。
+	// 机器人主动发送的私信每天有条数限制，只发一条消息，放不下时只导出前面的名次
+	content := fmt.Sprintf("成语接龙%s积分榜，共%d名玩家：\n", name, len(board))
+	csvText := splitLines(string(leaderboardCSV(board)), exportMessageSize-len(content))[0]
+	content += csvText
+	if rows := strings.Count(csvText, "\n") - 1; rows < len(board) {
+		content += fmt.Sprintf("私信长度有限，只导出了前%d名，完整数据请在服务器上用 bot export 导出。", rows)
+	}
+	if err := p.sendDirect(ctx, data, content); err != nil {
+		log.Println(err)
+		return "私信发送失败，请确认允许机器人发送私信后再试。"
+	}
+	return fmt.Sprintf("%s积分榜已私信发送。", name)
+}
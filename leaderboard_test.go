@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeaderboardViewTop(t *testing.T) {
+	p := Processor{store: newTestStore(t)}
+	ctx := context.Background()
+	now := time.Now()
+	// u1 到 u25 的积分依次递减
+	for i := 1; i <= 25; i++ {
+		r := ScoreRecord{UserID: fmt.Sprintf("u%d", i), GuildID: "g1", ChannelID: "c1", Name: fmt.Sprintf("玩家%d", i), Score: 100 - i, CreatedAt: now}
+		if err := p.store.RecordScore(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scope := BoardScope{GuildID: "g1"}
+
+	view := p.leaderboardView(ctx, scope, PeriodAll, "u23")
+	if len(view.Board) != boardTop || view.Total != 25 {
+		t.Fatalf("board has %d of %d entries, want %d of 25", len(view.Board), view.Total, boardTop)
+	}
+	if view.Mine == nil || view.Mine.Rank != 23 || view.Mine.UserID != "u23" {
+		t.Errorf("caller rank = %+v, want 23", view.Mine)
+	}
+	md := view.Markdown().Content
+	if !strings.Contains(md, "你：第23名") || !strings.Contains(md, "共25名玩家上榜") {
+		t.Errorf("markdown misses the caller rank: %s", md)
+	}
+	if n := len(view.Embed().Fields); n != boardTop+2 {
+		t.Errorf("embed has %d fields, want %d", n, boardTop+2)
+	}
+
+	// 查询者在榜单内或没有上榜时不单独展示
+	for _, userID := range []string{"u3", "u99"} {
+		if view := p.leaderboardView(ctx, scope, PeriodAll, userID); view.Mine != nil {
+			t.Errorf("%s: unexpected caller rank %+v", userID, view.Mine)
+		}
+	}
+}
+
+func TestHelpListsAdminCommands(t *testing.T) {
+	help := strings.Join(helpLines, "\n")
+	for _, cmd := range []string{"新赛季", "查询玩家", "导出积分榜"} {
+		if !strings.Contains(help, cmd) {
+			t.Errorf("help does not mention %s", cmd)
+		}
+	}
+}
@@ -4,25 +4,60 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"path"
 	"runtime"
+	"runtime/debug"
 	"strings"
 	"time"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 // 消息处理器，持有 openapi 对象
 var processor Processor
 
 func main() {
-	CreateDatabase()
 	ctx := context.Background()
+	// 运维子命令，如 bot migrate status
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, os.Args[1:]); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
 	// 加载 appid 和 token
 	botToken := New(TypeBot)
 	if err := botToken.LoadFromConfig(getConfigPath("config.yaml")); err != nil {
 		log.Fatalln(err)
 	}
+	c, err := LoadConfig(getConfigPath("config.yaml"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	conf = c
+	// 加载成语词库
+	if idiomDict, err = LoadIdiomDict(conf.IdiomDict); err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("idiom dict loaded, %d idioms\n", idiomDict.Len())
+	// 打开数据库，整个进程共用一个连接池
+	store, err := OpenStorage(ctx, conf.Database)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer store.Close()
+	// 启动时执行未执行的数据库迁移
+	migrated, err := store.MigrateUp(ctx, 0)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	for _, m := range migrated {
+		log.Printf("database migrated to %04d_%s\n", m.Version, m.Name)
+	}
+	if err := claimLegacyScores(ctx, store); err != nil {
+		log.Fatalln(err)
+	}
+	// 定时备份 SQLite 数据库
+	go runBackups(store)
 
 	// 初始化 openapi，正式环境
 	api := NewOpenAPI(botToken).WithTimeout(3 * time.Second)
@@ -33,7 +68,18 @@ func main() {
 		log.Fatalln(err)
 	}
 
-	processor = Processor{api: api}
+	// 进行中的成语接龙与多人接龙
+	games, err := NewGameStore(conf.GameStore, store)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	relays, err := NewRelayStore(conf.GameStore, store)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	processor = Processor{api: api, store: store, games: games, relays: relays, timers: newGameTimers()}
+	processor.restoreGames()
+	processor.restoreRelays()
 	// 根据不同的回调，生成 intents
 	intent := RegisterHandlers(
 		// at 机器人事件，目前是在这个事件处理中有逻辑，会回消息，其他的回调处理都只把数据打印出来，不做任何处理
@@ -65,7 +111,14 @@ func ErrorNotifyHandler() ErrorNotifyHandlers {
 
 // ATMessageEventHandler 实现处理 at 消息的回调
 func ATMessageEventHandler() ATMessageEventHandlers {
-	return func(event *WSPayload, data *Message) error {
+	return func(event *WSPayload, data *Message) (err error) {
+		// 单条指令处理出错不应影响整个连接
+		defer func() {
+			if e := recover(); e != nil {
+				log.Printf("process message %s panic: %v\n%s", data.ID, e, debug.Stack())
+				err = fmt.Errorf("process message panic: %v", e)
+			}
+		}()
 		input := strings.ToLower(ETLInput(data.Content))
 		return processor.ProcessMessage(input, data)
 	}
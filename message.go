@@ -72,10 +72,22 @@ type DirectMessageToCreate struct {
 
 // MessageToCreate 发送消息结构体定义
 type MessageToCreate struct {
-	Content string `json:"content,omitempty"`
-	Image   string `json:"image,omitempty"`
-	MsgID   string `json:"msg_id,omitempty"`
-	EventID string `json:"event_id,omitempty"` // 要回复的事件id, 逻辑同MsgID
+	Content  string           `json:"content,omitempty"`
+	Embed    *Embed           `json:"embed,omitempty"`
+	Ark      *Ark             `json:"ark,omitempty"`
+	Image    string           `json:"image,omitempty"`
+	Markdown *Markdown        `json:"markdown,omitempty"`
+	Keyboard *MessageKeyboard `json:"keyboard,omitempty"`
+	// 要回复的消息id，为空是主动消息，公域机器人会异步审核，不为空是被动消息，公域机器人会校验语料
+	MsgID            string            `json:"msg_id,omitempty"`
+	MessageReference *MessageReference `json:"message_reference,omitempty"`
+	EventID          string            `json:"event_id,omitempty"` // 要回复的事件id, 逻辑同MsgID
+}
+
+// MessageReference 引用消息
+type MessageReference struct {
+	MessageID             string `json:"message_id"`               // 消息 id
+	IgnoreGetMessageError bool   `json:"ignore_get_message_error"` // 是否忽略获取消息失败错误
 }
 
 const (
@@ -161,11 +173,13 @@ func (o *openAPI) PostDirectMessage(ctx context.Context,
 type CMD struct {
 	Cmd     string
 	Content string
+	Args    []string
 }
 
-var atRE = regexp.MustCompile(`<@!\d+>`)
+// atRE 匹配消息开头 @机器人 的部分，消息中间的 @用户 作为参数保留
+var atRE = regexp.MustCompile(`^(\s*<@!?\d+>)+`)
 
-const spaceCharSet = " \u00A0"
+const spaceCharSet = " \u00A0\u3000"
 
 func ETLInput(input string) string {
 	etlData := string(atRE.ReplaceAll([]byte(input), []byte("")))
@@ -175,16 +189,20 @@ func ETLInput(input string) string {
 
 func ParseCommand(input string) *CMD {
 	input = ETLInput(input)
-	s := strings.Split(input, " ")
+	s := SplitArgs(input)
+	if len(s) == 0 {
+		return &CMD{}
+	}
 	if len(s) < 2 {
 		return &CMD{
-			Cmd:     strings.Trim(input, spaceCharSet),
+			Cmd:     s[0],
 			Content: "",
 		}
 	}
 	return &CMD{
-		Cmd:     strings.Trim(s[0], spaceCharSet),
+		Cmd:     s[0],
 		Content: strings.Join(s[1:], " "),
+		Args:    s[1:],
 	}
 }
 
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// MaxImageSize 上传图片的大小限制
+const MaxImageSize = 10 << 20
+
+// 支持上传的图片类型
+var allowedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+}
+
+var (
+	// ErrImageEmpty 图片数据为空
+	ErrImageEmpty = errors.New("image is empty")
+	// ErrImageTooLarge 图片超出大小限制
+	ErrImageTooLarge = fmt.Errorf("image exceeds %d bytes", MaxImageSize)
+)
+
+// DetectImageType 根据文件内容识别图片类型，不支持的类型返回错误
+func DetectImageType(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", ErrImageEmpty
+	}
+	if len(data) > MaxImageSize {
+		return "", ErrImageTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	if !allowedImageTypes[contentType] {
+		return "", fmt.Errorf("unsupported image type %s", contentType)
+	}
+	return contentType, nil
+}
+
+// PostMessageImage 上传内存中的图片并发送消息，name 为上传时使用的文件名
+func (o *openAPI) PostMessageImage(ctx context.Context, channelID string,
+	msg *MessageToCreate, name string, image []byte) (*Message, error) {
+	req := o.request(ctx).SetPathParam("channel_id", channelID)
+	return o.postMultipart(req, o.getURL(messagesURI), msg, name, image)
+}
+
+// PostMessageFile 上传本地图片文件并发送消息
+func (o *openAPI) PostMessageFile(ctx context.Context, channelID string,
+	msg *MessageToCreate, file string) (*Message, error) {
+	image, err := readImageFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return o.PostMessageImage(ctx, channelID, msg, filepath.Base(file), image)
+}
+
+// PostDirectMessageImage 在私信频道内上传图片并发送消息
+func (o *openAPI) PostDirectMessageImage(ctx context.Context, dm *DirectMessage,
+	msg *MessageToCreate, name string, image []byte) (*Message, error) {
+	req := o.request(ctx).SetPathParam("guild_id", dm.GuildID)
+	return o.postMultipart(req, o.getURL(dmsURI), msg, name, image)
+}
+
+// readImageFile 读取本地图片，读取前先检查大小，避免把过大的文件读入内存
+func readImageFile(file string) ([]byte, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > MaxImageSize {
+		return nil, ErrImageTooLarge
+	}
+	return os.ReadFile(file)
+}
+
+// postMultipart 以 multipart/form-data 发送消息，图片放在 file_image 字段
+func (o *openAPI) postMultipart(req *resty.Request, url string,
+	msg *MessageToCreate, name string, image []byte) (*Message, error) {
+	contentType, err := DetectImageType(image)
+	if err != nil {
+		return nil, err
+	}
+	form, err := multipartForm(msg)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := req.
+		SetResult(Message{}).
+		SetFormData(form).
+		SetMultipartField("file_image", name, contentType, bytes.NewReader(image)).
+		Post(url)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Result().(*Message), nil
+}
+
+// multipartForm 消息中除图片外的字段，结构体字段与 JSON 请求中的写法一致，编码为 JSON 字符串
+func multipartForm(msg *MessageToCreate) (map[string]string, error) {
+	form := map[string]string{}
+	if msg.Content != "" {
+		form["content"] = msg.Content
+	}
+	if msg.MsgID != "" {
+		form["msg_id"] = msg.MsgID
+	}
+	if msg.EventID != "" {
+		form["event_id"] = msg.EventID
+	}
+	objects := []struct {
+		field string
+		value interface{}
+		set   bool
+	}{
+		{"embed", msg.Embed, msg.Embed != nil},
+		{"ark", msg.Ark, msg.Ark != nil},
+		{"markdown", msg.Markdown, msg.Markdown != nil},
+		{"keyboard", msg.Keyboard, msg.Keyboard != nil},
+		{"message_reference", msg.MessageReference, msg.MessageReference != nil},
+	}
+	for _, obj := range objects {
+		if !obj.set {
+			continue
+		}
+		b, err := json.Marshal(obj.value)
+		if err != nil {
+			return nil, err
+		}
+		form[obj.field] = string(b)
+	}
+	return form, nil
+}
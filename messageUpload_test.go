@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+func TestMultipartForm(t *testing.T) {
+	msg := &MessageToCreate{
+		Content:          "今日积分榜",
+		MsgID:            "m1",
+		Embed:            NewEmbed("标题", "摘要"),
+		Ark:              NewArk(ArkTemplateTextImg).AddKV("#DESC#", "说明"),
+		Markdown:         NewMarkdown("# 标题"),
+		Keyboard:         &MessageKeyboard{ID: "k1"},
+		MessageReference: &MessageReference{MessageID: "m0", IgnoreGetMessageError: true},
+	}
+	form, err := multipartForm(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"content":           "今日积分榜",
+		"msg_id":            "m1",
+		"embed":             `{"title":"标题","prompt":"摘要"}`,
+		"ark":               `{"template_id":24,"kv":[{"key":"#DESC#","value":"说明"}]}`,
+		"markdown":          `{"content":"# 标题"}`,
+		"keyboard":          `{"id":"k1"}`,
+		"message_reference": `{"message_id":"m0","ignore_get_message_error":true}`,
+	}
+	if !reflect.DeepEqual(form, want) {
+		t.Errorf("multipartForm =\n%v\nwant\n%v", form, want)
+	}
+
+	form, err = multipartForm(&MessageToCreate{})
+	if err != nil || len(form) != 0 {
+		t.Errorf("multipartForm(empty) = %v, %v, want empty", form, err)
+	}
+}
+
+func TestDetectImageType(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := DetectImageType(buf.Bytes()); err != nil || got != "image/png" {
+		t.Errorf("DetectImageType(png) = %q, %v", got, err)
+	}
+	if _, err := DetectImageType(nil); !errors.Is(err, ErrImageEmpty) {
+		t.Errorf("DetectImageType(nil) error = %v, want ErrImageEmpty", err)
+	}
+	if _, err := DetectImageType(make([]byte, MaxImageSize+1)); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("DetectImageType(large) error = %v, want ErrImageTooLarge", err)
+	}
+	if _, err := DetectImageType([]byte("plain text")); err == nil {
+		t.Error("DetectImageType accepted plain text")
+	}
+}
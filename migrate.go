@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles 数据库迁移脚本，按方言分目录存放，如 migrations/sqlite/0001_score_ledger.up.sql
+// 和 migrations/sqlite/0001_score_ledger.down.sql，各方言的编号与名称保持一致
+// 已发布的脚本不要再修改，表结构有变化时在每个方言的目录中新增一个编号更大的脚本
+//
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// Migration 一次数据库迁移
+type Migration struct {
+	Version int
+	Name    string
+	Up      string // 升级脚本
+	Down    string // 回滚脚本
+}
+
+// MigrationStatus 迁移的执行情况
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// loadMigrations 读取方言内置的迁移脚本，按编号从小到大排列
+func loadMigrations(d dialect) ([]Migration, error) {
+	dir := path.Join("migrations", d.name)
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		file := entry.Name()
+		base := strings.TrimSuffix(file, ".sql")
+		direction := path.Ext(base)
+		base = strings.TrimSuffix(base, direction)
+		number, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name: %s", file)
+		}
+		content, err := migrationFiles.ReadFile(path.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %s, %s", version, m.Name, name)
+		}
+		switch direction {
+		case ".up":
+			m.Up = string(content)
+		case ".down":
+			m.Down = string(content)
+		default:
+			return nil, fmt.Errorf("invalid migration file name: %s", file)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// ensureMigrationTable 创建迁移记录表
+// 迁移机制之前创建的 SQLite 数据库中的旧表由第一批迁移脚本处理，这里只建记录表
+func (s *Store) ensureMigrationTable(ctx context.Context) error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at BIGINT NOT NULL
+	);`
+	if _, err := s.db.ExecContext(ctx, createTableSQL); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// MigrationStatus 全部迁移及其执行情况
+func (s *Store) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(s.dialect)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureMigrationTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = time.Unix(appliedAt, 0)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		at, ok := applied[m.Version]
+		status = append(status, MigrationStatus{Migration: m, Applied: ok, AppliedAt: at})
+	}
+	return status, nil
+}
+
+// MigrateUp 依次执行未执行的迁移直到 target 版本，target 为 0 时执行全部，返回本次执行的迁移
+func (s *Store) MigrateUp(ctx context.Context, target int) ([]Migration, error) {
+	status, err := s.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, m := range status {
+		if target > 0 && m.Version > target {
+			break
+		}
+		if m.Applied {
+			continue
+		}
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, s.rebind(
+				"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+				m.Version, m.Name, time.Now().Unix(),
+			)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m.Migration)
+	}
+	return done, nil
+}
+
+// MigrateDown 从最新的迁移开始回滚 steps 个，返回本次回滚的迁移
+func (s *Store) MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	status, err := s.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for i := len(status) - 1; i >= 0 && len(done) < steps; i-- {
+		m := status[i]
+		if !m.Applied {
+			continue
+		}
+		if m.Down == "" {
+			return done, fmt.Errorf("migration %04d_%s cannot be reverted", m.Version, m.Name)
+		}
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, s.rebind("DELETE FROM schema_migrations WHERE version = ?"), m.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("revert migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m.Migration)
+	}
+	return done, nil
+}
+
+// inTx 在数据库的事务中执行 fn
+func (s *Store) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return inTx(ctx, s.db, fn)
+}
+
+// inTx 在事务中执行 fn，fn 返回错误时回滚
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMigrateLegacyUserScores(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := sql.Open(sqliteDialect.driver, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := legacy.Exec(`
+	CREATE TABLE userscores (id TEXT PRIMARY KEY, score INTEGER NOT NULL);
+	INSERT INTO userscores (id, score) VALUES ('小明', 12), ('小红', 30), ('小刚', 0);`); err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	store, err := OpenStorage(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := store.MigrateUp(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	board, err := store.QueryLeaderboard(ctx, BoardScope{}, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(board) != 2 || board[0].Name != "小红" || board[0].Score != 30 || board[0].UserID != "legacy:小红" ||
+		board[1].Name != "小明" || board[1].Score != 12 {
+		t.Errorf("unexpected legacy board: %+v", board)
+	}
+	today, err := store.QueryLeaderboard(ctx, BoardScope{}, startOfDay(time.Now()), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(today) != 0 {
+		t.Errorf("legacy scores should not count for today: %+v", today)
+	}
+
+	// 旧积分不是真实的用户，不建玩家资料
+	players, err := store.ListPlayers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(players) != 0 {
+		t.Errorf("legacy scores created player profiles: %+v", players)
+	}
+
+	// 按配置归入频道后出现在该频道的总榜中，再次归入不会重复处理
+	n, err := store.ClaimLegacyScores(ctx, "g1")
+	if err != nil || n != 2 {
+		t.Fatalf("ClaimLegacyScores = %d, %v, want 2", n, err)
+	}
+	guild, err := store.QueryLeaderboard(ctx, BoardScope{GuildID: "g1"}, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(guild) != 2 || guild[0].UserID != "legacy:小红" {
+		t.Errorf("guild board after claiming legacy scores: %+v", guild)
+	}
+	if n, err := store.ClaimLegacyScores(ctx, "g2"); err != nil || n != 0 {
+		t.Errorf("second ClaimLegacyScores = %d, %v, want 0", n, err)
+	}
+
+	// 回滚全部迁移后旧表中的积分恢复原样
+	if _, err := store.MigrateDown(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+	var restored int
+	if err := store.(*Store).db.QueryRowContext(ctx, "SELECT score FROM userscores WHERE id = '小红'").Scan(&restored); err != nil {
+		t.Fatal(err)
+	}
+	if restored != 30 {
+		t.Errorf("restored legacy score = %d, want 30", restored)
+	}
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_score_ledger_user;
+DROP INDEX IF EXISTS idx_score_ledger_created_at;
+DROP TABLE IF EXISTS score_ledger;
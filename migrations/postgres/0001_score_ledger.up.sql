@@ -0,0 +1,12 @@
+-- 积分流水：每局结束时每名得分的玩家记一条，积分榜由流水汇总而来
+CREATE TABLE IF NOT EXISTS score_ledger (
+	id BIGSERIAL PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	guild_id TEXT NOT NULL,
+	channel_id TEXT NOT NULL,
+	display_name TEXT NOT NULL,
+	score INTEGER NOT NULL,
+	created_at BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_score_ledger_created_at ON score_ledger (created_at);
+CREATE INDEX IF NOT EXISTS idx_score_ledger_user ON score_ledger (user_id, created_at);
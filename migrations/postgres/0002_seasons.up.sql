@@ -0,0 +1,7 @@
+-- 赛季：只记录每个赛季的开始时间，开启新赛季不会删除任何积分
+CREATE TABLE IF NOT EXISTS seasons (
+	id SERIAL PRIMARY KEY,
+	guild_id TEXT NOT NULL DEFAULT '',
+	name TEXT NOT NULL,
+	started_at BIGINT NOT NULL
+);
@@ -0,0 +1,4 @@
+-- 用户
+CREATE TABLE IF NOT EXISTS users (
+	user_id TEXT PRIMARY KEY
+);
@@ -0,0 +1,6 @@
+CREATE TABLE users (
+	user_id TEXT PRIMARY KEY
+);
+INSERT INTO users (user_id) SELECT user_id FROM players;
+DROP INDEX IF EXISTS idx_players_nickname;
+DROP TABLE players;
@@ -0,0 +1,24 @@
+-- 玩家资料，取代只有用户ID的 users 表，偏好设置以 JSON 保存
+CREATE TABLE players (
+	user_id TEXT PRIMARY KEY,
+	nickname TEXT NOT NULL DEFAULT '',
+	avatar TEXT NOT NULL DEFAULT '',
+	first_seen BIGINT NOT NULL,
+	last_seen BIGINT NOT NULL,
+	games_played INTEGER NOT NULL DEFAULT 0,
+	best_streak INTEGER NOT NULL DEFAULT 0,
+	preferences TEXT NOT NULL DEFAULT '{}'
+);
+CREATE INDEX idx_players_nickname ON players (nickname);
+-- 已有积分的玩家按积分流水补上资料，早期版本以用户名为键的 legacy: 积分不是真实的用户，不建资料
+INSERT INTO players (user_id, nickname, first_seen, last_seen)
+SELECT l.user_id,
+	(SELECT n.display_name FROM score_ledger n WHERE n.user_id = l.user_id ORDER BY n.created_at DESC, n.id DESC LIMIT 1),
+	MIN(l.created_at), MAX(l.created_at)
+FROM score_ledger l
+WHERE l.user_id NOT LIKE 'legacy:%'
+GROUP BY l.user_id;
+INSERT INTO players (user_id, first_seen, last_seen)
+SELECT user_id, EXTRACT(EPOCH FROM now())::BIGINT, EXTRACT(EPOCH FROM now())::BIGINT
+FROM users WHERE user_id NOT IN (SELECT user_id FROM players);
+DROP TABLE users;
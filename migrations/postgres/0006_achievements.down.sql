@@ -0,0 +1,2 @@
+ALTER TABLE players DROP COLUMN day_streak;
+DROP TABLE achievements;
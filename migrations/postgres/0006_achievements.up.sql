@@ -0,0 +1,9 @@
+-- 玩家解锁的成就，成就的定义写在代码中，这里只记录解锁的时间
+CREATE TABLE achievements (
+	user_id TEXT NOT NULL,
+	achievement_id TEXT NOT NULL,
+	unlocked_at BIGINT NOT NULL,
+	PRIMARY KEY (user_id, achievement_id)
+);
+-- 连续活跃的天数，用于连续登录类的成就
+ALTER TABLE players ADD COLUMN day_streak INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,10 @@
+-- 把从早期 userscores 表复制来的积分放回原表
+CREATE TABLE IF NOT EXISTS userscores (
+	id TEXT PRIMARY KEY,
+	score INTEGER NOT NULL
+);
+INSERT OR REPLACE INTO userscores (id, score)
+SELECT substr(user_id, 8), score FROM score_ledger WHERE user_id LIKE 'legacy:%';
+DROP INDEX IF EXISTS idx_score_ledger_user;
+DROP INDEX IF EXISTS idx_score_ledger_created_at;
+DROP TABLE IF EXISTS score_ledger;
@@ -0,0 +1,23 @@
+-- 积分流水：每局结束时每名得分的玩家记一条，积分榜由流水汇总而来
+CREATE TABLE IF NOT EXISTS score_ledger (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id TEXT NOT NULL,
+	guild_id TEXT NOT NULL,
+	channel_id TEXT NOT NULL,
+	display_name TEXT NOT NULL,
+	score INTEGER NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_score_ledger_created_at ON score_ledger (created_at);
+CREATE INDEX IF NOT EXISTS idx_score_ledger_user ON score_ledger (user_id, created_at);
+
+-- 早期版本把每名玩家的积分存在 userscores 中，以用户名为键，没有频道和时间。
+-- 一次性复制到积分流水：user_id 加 legacy: 前缀以免与真实的用户ID冲突，频道为空，只计入全服总榜，
+-- 时间记为 0，不会出现在今日和本周的积分榜中；复制后删除旧表，新建的数据库中这张表是空的
+CREATE TABLE IF NOT EXISTS userscores (
+	id TEXT PRIMARY KEY,
+	score INTEGER NOT NULL
+);
+INSERT INTO score_ledger (user_id, guild_id, channel_id, display_name, score, created_at)
+SELECT 'legacy:' || id, '', '', id, score, 0 FROM userscores WHERE score > 0;
+DROP TABLE userscores;
@@ -0,0 +1,10 @@
+-- 进行中的成语接龙，每局的状态以 JSON 保存，finished 为 1 的记录是该玩家的上一局
+CREATE TABLE IF NOT EXISTS idiom_games (
+	guild_id TEXT NOT NULL,
+	channel_id TEXT NOT NULL,
+	user_id TEXT NOT NULL,
+	finished INTEGER NOT NULL DEFAULT 0,
+	state TEXT NOT NULL,
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (guild_id, channel_id, user_id, finished)
+);
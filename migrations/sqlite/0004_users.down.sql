@@ -0,0 +1,6 @@
+CREATE TABLE users_old (
+	userid TEXT PRIMARY KEY
+);
+INSERT OR IGNORE INTO users_old (userid) SELECT user_id FROM users;
+DROP TABLE users;
+ALTER TABLE users_old RENAME TO users;
@@ -0,0 +1,10 @@
+-- 用户表改用与代码一致的 user_id 列，早期的表声明的是 userid
+CREATE TABLE IF NOT EXISTS users (
+	userid TEXT PRIMARY KEY
+);
+CREATE TABLE users_new (
+	user_id TEXT PRIMARY KEY
+);
+INSERT OR IGNORE INTO users_new (user_id) SELECT CAST(userid AS TEXT) FROM users;
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
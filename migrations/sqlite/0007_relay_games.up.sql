@@ -0,0 +1,6 @@
+-- 进行中的多人接龙，每个子频道同时只有一局，状态以 JSON 保存
+CREATE TABLE IF NOT EXISTS relay_games (
+	channel_id TEXT PRIMARY KEY,
+	state TEXT NOT NULL,
+	updated_at INTEGER NOT NULL
+);
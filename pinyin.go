@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// pinyinArgs 带声调、多音字返回全部读音
+var pinyinArgs = func() pinyin.Args {
+	a := pinyin.NewArgs()
+	a.Style = pinyin.Tone
+	a.Heteronym = true
+	return a
+}()
+
+// charPinyin 查询单个汉字的全部读音（带声调），常用读音在前，非汉字返回空
+func charPinyin(r rune) []string {
+	return pinyin.SinglePinyin(r, pinyinArgs)
+}
+
+// wordPinyin 按字取常用读音生成整词拼音，用于补全词库中缺失的拼音，有非汉字时返回空
+func wordPinyin(word string) string {
+	var syllables []string
+	for _, r := range word {
+		p := charPinyin(r)
+		if len(p) == 0 {
+			return ""
+		}
+		syllables = append(syllables, p[0])
+	}
+	return strings.Join(syllables, " ")
+}
+
+// toneless 带声调的韵母与不带声调韵母的对应
+var toneless = strings.NewReplacer(
+	"ā", "a", "á", "a", "ǎ", "a", "à", "a",
+	"ē", "e", "é", "e", "ě", "e", "è", "e",
+	"ī", "i", "í", "i", "ǐ", "i", "ì", "i",
+	"ō", "o", "ó", "o", "ǒ", "o", "ò", "o",
+	"ū", "u", "ú", "u", "ǔ", "u", "ù", "u",
+	"ǖ", "ü", "ǘ", "ü", "ǚ", "ü", "ǜ", "ü",
+	"ń", "n", "ň", "n", "ǹ", "n", "ḿ", "m",
+)
+
+// stripTone 去掉音节的声调，如 zhú -> zhu
+func stripTone(syllable string) string {
+	return toneless.Replace(syllable)
+}
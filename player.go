@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// lookupLimit 按名字查询玩家时最多列出的人数
+const lookupLimit = 5
+
+// seePlayer 记录消息发送者的这次互动，第一次互动时自动建立玩家资料，并判定连续活跃的成就
+func (p Processor) seePlayer(ctx context.Context, data *Message) {
+	if data.Author == nil || data.Author.Bot {
+		return
+	}
+	dayStreak, err := p.store.SeePlayer(ctx, data.Author.ID, displayName(data), data.Author.Avatar, time.Now())
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	p.seeAchievements(ctx, data, dayStreak)
+}
+
+// preferredOptions 玩家偏好的开局选项，没有设置时为空
+func (p Processor) preferredOptions(ctx context.Context, userID string) string {
+	player, err := p.store.QueryPlayer(ctx, userID)
+	if err != nil {
+		if !errors.Is(err, ErrNoPlayer) {
+			log.Println(err)
+		}
+		return ""
+	}
+	return strings.TrimSpace(player.Prefs.Mode + " " + player.Prefs.Difficulty)
+}
+
+// profileText 玩家资料的文字说明
+func profileText(player Player) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "昵称：%s", player.Nickname)
+	fmt.Fprintf(&b, "\n用户ID：%s", player.UserID)
+	fmt.Fprintf(&b, "\n首次互动：%s", player.FirstSeen.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "\n最近活跃：%s", player.LastSeen.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "\n参与局数：%d", player.GamesPlayed)
+	fmt.Fprintf(&b, "\n最长连击：%d", player.BestStreak)
+	fmt.Fprintf(&b, "\n开局偏好：%s", player.Prefs)
+	return b.String()
+}
+
+// String 偏好设置的文字说明
+func (prefs PlayerPrefs) String() string {
+	mode, difficulty := prefs.Mode, prefs.Difficulty
+	if mode == "" {
+		mode = ChainSameChar.String()
+	}
+	if difficulty == "" {
+		difficulty = DifficultyNormal.String()
+	}
+	return fmt.Sprintf("%s模式，%s难度", mode, difficulty)
+}
+
+// myProfile 消息发送者的玩家资料
+func (p Processor) myProfile(ctx context.Context, data *Message) string {
+	player, err := p.store.QueryPlayer(ctx, data.Author.ID)
+	if err != nil {
+		log.Println(err)
+		return "资料暂时无法查询，请稍后再试。"
+	}
+	return fmt.Sprintf("你好，%s的资料：\n%s", displayName(data), profileText(player))
+}
+
+// setPreferences 设置开局时默认的模式与难度，省略参数时恢复为同字模式、普通难度
+func (p Processor) setPreferences(ctx context.Context, data *Message, args *Args) string {
+	mode, difficulty, err := parseGameOptions(args.String("选项"))
+	if err != nil {
+		return err.Error()
+	}
+	prefs := PlayerPrefs{Mode: mode.String(), Difficulty: difficulty.String()}
+	if err := p.store.SavePreferences(ctx, data.Author.ID, prefs); err != nil {
+		log.Println(err)
+		return "偏好暂时无法保存，请稍后再试。"
+	}
+	return fmt.Sprintf("已保存，之后发送“成语接龙”默认为%s。", prefs)
+}
+
+// lookupPlayer 管理员查询玩家资料，可以@玩家，也可以填写用户ID或名字
+func (p Processor) lookupPlayer(ctx context.Context, data *Message, args *Args) string {
+	if !isAdmin(data) {
+		return "只有管理员可以查询玩家资料。"
+	}
+	query := args.String("玩家")
+	for _, u := range data.Mentions {
+		if !u.Bot {
+			query = u.ID
+			break
+		}
+	}
+	if query == "" {
+		return "请@要查询的玩家，或者填写用户ID、名字，如：查询玩家 小明"
+	}
+
+	player, err := p.store.QueryPlayer(ctx, query)
+	if err == nil {
+		return profileText(player)
+	}
+	if !errors.Is(err, ErrNoPlayer) {
+		log.Println(err)
+		return "资料暂时无法查询，请稍后再试。"
+	}
+	players, err := p.store.FindPlayers(ctx, query, lookupLimit)
+	if err != nil {
+		log.Println(err)
+		return "资料暂时无法查询，请稍后再试。"
+	}
+	switch len(players) {
+	case 0:
+		return fmt.Sprintf("没有找到“%s”的资料。", query)
+	case 1:
+		return profileText(players[0])
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "名字含有“%s”的玩家有多名，请用用户ID查询：", query)
+	for _, player := range players {
+		fmt.Fprintf(&b, "\n%s  %s  最近活跃 %s", player.Nickname, player.UserID, player.LastSeen.Format("2006-01-02"))
+	}
+	return b.String()
+}
@@ -2,226 +2,597 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
 	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
-	"unicode/utf8"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // Processor is a struct to process message
 type Processor struct {
-	api OpenAPI
+	api    OpenAPI
+	store  Storage     // 积分、赛季等持久化数据
+	games  GameStore   // 进行中的成语接龙
+	relays RelayStore  // 进行中的多人接龙
+	timers *gameTimers // 成语接龙的回合计时器
 }
 
-var IdiomUsers = map[string]IdiomUser{}
-
+// IdiomUser 一名玩家的一局成语接龙
 type IdiomUser struct {
-	score    int
-	lastward rune
+	score      int
+	lastward   rune
+	lastPinyin string          // 上一个成语尾字的读音
+	mode       ChainMode       // 接龙模式
+	difficulty Difficulty      // 难度
+	streak     int             // 连续答对的个数
+	bestStreak int             // 本局最长连击
+	hinted     bool            // 本回合是否用过提示
+	turnStart  time.Time       // 本回合开始的时间
+	breakdown  ScoreBreakdown  // 本局积分明细
+	hintWord   string          // 本回合提示的答案
+	hintLevel  int             // 本回合已提示到第几级
+	hintsUsed  int             // 本局已用的提示次数
+	used       map[string]bool // 本局已用过的成语
+	chain      []ChainStep     // 本局按顺序给出的成语
+	lastMsg    *Message        // 最近一条相关消息，用于计时器触发时被动回复
 }
 
-const (
-	start int = 1
-	stop  int = 0
-)
-
-var size int //成语长度
-
 // ProcessMessage is a function to process message
 func (p Processor) ProcessMessage(input string, data *Message) error {
-	fmt.Printf("data.ChannelID: %v\n", data.ChannelID)
 	ctx := context.Background()
 	cmd := ParseCommand(input)
 	toCreate := &MessageToCreate{
 		Content: "默认回复"}
+	p.seePlayer(ctx, data)
+	args, err := cmd.Bind()
+	if err != nil {
+		toCreate.Content = err.Error()
+		p.replyQuote(ctx, data, toCreate)
+		return nil
+	}
 
 	switch cmd.Cmd {
 	case "hi":
-		toCreate.Content = hiReply()
-		if _, err := p.api.PostMessage(ctx, data.ChannelID, toCreate); err != nil {
-			log.Println(err)
-		}
+		setHelp(toCreate)
+		p.reply(ctx, data, toCreate)
 	case "加法运算":
-		toCreate.Content = genReplyContent(data, input)
-		if _, err := p.api.PostMessage(ctx, data.ChannelID, toCreate); err != nil {
-			log.Println(err)
-		}
+		toCreate.Content = genReplyContent(data, args)
+		p.reply(ctx, data, toCreate)
 	case "成语接龙":
-		toCreate.Content = genReplyidiom(data, input)
-		if _, err := p.api.PostMessage(ctx, data.ChannelID, toCreate); err != nil {
-			log.Println(err)
-		}
+		toCreate.Content = p.genReplyidiom(ctx, data, args)
+		p.reply(ctx, data, toCreate)
+	case "多人接龙":
+		toCreate.Content = p.startRelay(data, args)
+		p.reply(ctx, data, toCreate)
+	case "加入接龙":
+		toCreate.Content = p.joinRelay(data)
+		p.reply(ctx, data, toCreate)
 	case "提示":
-		toCreate.Content = hintIdiom(data, input)
-		if _, err := p.api.PostMessage(ctx, data.ChannelID, toCreate); err != nil {
-			log.Println(err)
+		toCreate.Content = p.hint(data, args)
+		p.reply(ctx, data, toCreate)
+	case "接龙记录":
+		toCreate.Content = p.chainRecord(data)
+		p.reply(ctx, data, toCreate)
+	case "结束接龙", "认输":
+		toCreate.Content = p.stopIdiom(data)
+		p.reply(ctx, data, toCreate)
+	case "积分榜":
+		periodName, scopeName := args.String("范围"), args.String("统计范围")
+		if _, ok := boardPeriodNames[periodName]; !ok && scopeName == "" {
+			// 只写了统计范围，如“积分榜 全服”
+			periodName, scopeName = "今日", periodName
+		}
+		period, ok := boardPeriodNames[periodName]
+		if !ok {
+			toCreate.Content = "可选范围：今日、本周、本月、本赛季、上赛季、总榜，如：积分榜 本周"
+			p.replyQuote(ctx, data, toCreate)
+			return nil
 		}
+		scope, err := parseBoardScope(data, scopeName)
+		if err != nil {
+			toCreate.Content = err.Error()
+			p.replyQuote(ctx, data, toCreate)
+			return nil
+		}
+		p.setLeaderboard(ctx, toCreate, data, scope, period)
+		p.reply(ctx, data, toCreate)
+	case "我的积分":
+		toCreate.Content = p.myScore(ctx, data)
+		p.replyQuote(ctx, data, toCreate)
+	case "我的资料":
+		toCreate.Content = p.myProfile(ctx, data)
+		p.replyQuote(ctx, data, toCreate)
+	case "我的成就":
+		toCreate.Content = p.myAchievements(ctx, data)
+		p.replyQuote(ctx, data, toCreate)
+	case "偏好设置":
+		toCreate.Content = p.setPreferences(ctx, data, args)
+		p.replyQuote(ctx, data, toCreate)
+	case "查询玩家":
+		toCreate.Content = p.lookupPlayer(ctx, data, args)
+		p.reply(ctx, data, toCreate)
+	case "导出积分榜":
+		toCreate.Content = p.exportLeaderboard(ctx, data, args)
+		p.reply(ctx, data, toCreate)
+	case "新赛季":
+		toCreate.Content = p.newSeason(ctx, data, args)
+		p.reply(ctx, data, toCreate)
 	case "私信":
 		p.dmHandler(data)
 		return nil
 	case "成语接龙玩家积分榜":
-		toCreate.Content = ShowIdiomTable()
-		if _, err := p.api.PostMessage(ctx, data.ChannelID, toCreate); err != nil {
-			log.Println(err)
-		}
+		p.setLeaderboard(ctx, toCreate, data, scopeOf(data), PeriodToday)
+		p.reply(ctx, data, toCreate)
 	default:
-		_, exist := IdiomUsers[data.Author.Username]
-		if exist {
-			toCreate.Content = genAnsweridiom(data, input)
-			if _, err := p.api.PostMessage(ctx, data.ChannelID, toCreate); err != nil {
-				log.Println(err)
-			}
+		// 子频道内的其他消息照常处理，只有像成语的输入才算多人接龙的作答
+		if looksLikeIdiom(input) && p.inRelay(data.ChannelID) {
+			toCreate.Content = p.answerRelay(data, input)
+			p.replyQuote(ctx, data, toCreate)
+		} else if p.inIdiomGame(data) {
+			toCreate.Content = p.genAnsweridiom(data, input)
+			p.replyQuote(ctx, data, toCreate)
 		} else {
 			toCreate.Content = "抱歉，此指令未知！"
-			if _, err := p.api.PostMessage(ctx, data.ChannelID, toCreate); err != nil {
-				log.Println(err)
-			}
+			p.reply(ctx, data, toCreate)
 		}
 	}
 	return nil
 }
 
+// passiveReplyWindow 被动回复的有效期，超过后只能发送主动消息
+const passiveReplyWindow = 5 * time.Minute
+
+// reply 以被动消息的形式回复触发指令的消息，超出被动回复有效期时退化为主动消息
+func (p Processor) reply(ctx context.Context, data *Message, toCreate *MessageToCreate) {
+	if canPassiveReply(data, time.Now()) {
+		toCreate.MsgID = data.ID
+	} else {
+		toCreate.MsgID = ""
+	}
+	if _, err := p.api.PostMessage(ctx, data.ChannelID, toCreate); err != nil {
+		log.Println(err)
+	}
+}
+
+// replyImage 以被动消息的形式回复一张图片
+func (p Processor) replyImage(ctx context.Context, data *Message, toCreate *MessageToCreate, name string, image []byte) {
+	if canPassiveReply(data, time.Now()) {
+		toCreate.MsgID = data.ID
+	} else {
+		toCreate.MsgID = ""
+	}
+	if _, err := p.api.PostMessageImage(ctx, data.ChannelID, toCreate, name, image); err != nil {
+		log.Println(err)
+	}
+}
+
+// replyQuote 回复并引用触发指令的消息
+func (p Processor) replyQuote(ctx context.Context, data *Message, toCreate *MessageToCreate) {
+	toCreate.MessageReference = &MessageReference{
+		MessageID:             data.ID,
+		IgnoreGetMessageError: true,
+	}
+	p.reply(ctx, data, toCreate)
+}
+
+// adminRoles 可以执行管理指令的身份组：2 为超级管理员，4 为频道主
+var adminRoles = map[string]bool{"2": true, "4": true}
+
+// isAdmin 消息发送者是否为管理员：频道主、超级管理员或配置文件中指定的用户
+func isAdmin(data *Message) bool {
+	for _, id := range conf.Admins {
+		if id == data.Author.ID {
+			return true
+		}
+	}
+	if data.Member == nil {
+		return false
+	}
+	for _, role := range data.Member.Roles {
+		if adminRoles[role] {
+			return true
+		}
+	}
+	return false
+}
+
+// canPassiveReply 消息是否仍在被动回复有效期内，时间戳无法解析时按有效处理，由服务端判定
+func canPassiveReply(data *Message, now time.Time) bool {
+	if data.ID == "" {
+		return false
+	}
+	sent, err := time.Parse(time.RFC3339, data.Timestamp)
+	if err != nil {
+		return true
+	}
+	return now.Sub(sent) < passiveReplyWindow
+}
+
 func (p Processor) dmHandler(data *Message) {
+	if err := p.sendDirect(context.Background(), data, "默认私信回复"); err != nil {
+		log.Println(err)
+	}
+}
+
+// sendDirect 依次私信发送多条文字消息给消息发送者
+func (p Processor) sendDirect(ctx context.Context, data *Message, contents ...string) error {
 	dm, err := p.api.CreateDirectMessage(
-		context.Background(), &DirectMessageToCreate{
+		ctx, &DirectMessageToCreate{
 			SourceGuildID: data.GuildID,
-			RecipientID:   data.Author.Username,
+			RecipientID:   data.Author.ID,
 		},
 	)
 	if err != nil {
-		log.Println(err)
-		return
+		return err
+	}
+	for _, content := range contents {
+		if _, err := p.api.PostDirectMessage(ctx, dm, &MessageToCreate{Content: content}); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	toCreate := &MessageToCreate{
-		Content: "默认私信回复",
+// helpLines 功能说明，帮助的 ark 与 markdown 共用
+var helpLines = []string{
+	"加法运算 19+16：计算加法算式",
+	"成语接龙 [同字|同音|谐音] [简单|普通|困难|挑战]：开始成语接龙游戏",
+	"提示 [人]：游戏中获取本回合的提示，不在游戏中时获取以“人”开头的成语",
+	"多人接龙 [模式] [轮流|抢答] [60秒]：在子频道内开始多人接龙，先答对者得分",
+	"加入接龙：加入子频道内的多人接龙",
+	"结束接龙：结束进行中的成语接龙并保存积分",
+	"接龙记录：查看当前或上一局的接龙记录",
+	"成语接龙玩家积分榜：查看玩家积分榜",
+	"积分榜 [今日|本周|本月|本赛季|上赛季|总榜] [本频道|本子频道|全服]：查看历史积分榜",
+	"我的积分：查看自己的积分、名次和个人最佳",
+	"我的资料：查看自己的玩家资料",
+	"我的成就：查看已解锁和未解锁的成就",
+	"偏好设置 [同字|同音|谐音] [简单|普通|困难|挑战]：设置成语接龙默认的模式和难度",
+	"私信：机器人会私信你",
+	"查询玩家 [@玩家|用户ID|名字]：（管理员）查询玩家资料",
+	"导出积分榜 [今日|本周|本月|本赛季|上赛季|总榜]：（管理员）以私信导出本频道的积分榜",
+	"新赛季 [名称]：（管理员）为本频道开启新赛季",
+}
+
+// helpIntro 功能说明的开头
+const helpIntro = "你好，欢迎使用问答机器人！本机器人可以为你提供加法运算和成语接龙服务："
+
+// helpArk 以链接+文本列表模板展示功能说明
+func helpArk() *Ark {
+	objs := []*ArkObj{NewArkObj("desc", helpIntro)}
+	for _, line := range helpLines {
+		objs = append(objs, NewArkObj("desc", line))
 	}
-	_, err = p.api.PostDirectMessage(
-		context.Background(), dm, toCreate,
-	)
-	if err != nil {
-		log.Println(err)
-		return
+	return NewArk(ArkTemplateLinkList).
+		AddKV("#DESC#", "问答机器人使用说明").
+		AddKV("#PROMPT#", "欢迎使用问答机器人！").
+		AddObjList("#LIST#", objs...)
+}
+
+// helpMarkdown 以 markdown 列表展示功能说明
+func helpMarkdown() *Markdown {
+	var b strings.Builder
+	b.WriteString("# 问答机器人使用说明\n" + helpIntro + "\n")
+	for _, line := range helpLines {
+		name, desc, _ := strings.Cut(line, "：")
+		fmt.Fprintf(&b, "- **%s**：%s\n", name, desc)
 	}
+	return NewMarkdown(b.String())
 }
 
-func hiReply() string {
-	var str = `你好：
-	欢迎使用问答机器人！
-	本机器人可以为你提供加法运算和成语接龙服务！
-	`
-	return fmt.Sprintf(str)
+// helpKeyboard 帮助下方的常用指令按钮
+func helpKeyboard() *MessageKeyboard {
+	return NewKeyboard().
+		AddRow(CommandButton("成语接龙", "成语接龙", true), CommandButton("多人接龙", "多人接龙", true)).
+		AddRow(CommandButton("积分榜", "积分榜", true), CommandButton("我的积分", "我的积分", true), CommandButton("我的成就", "我的成就", true))
+}
+
+// setHelp 按是否开通 markdown 填充帮助消息
+func setHelp(toCreate *MessageToCreate) {
+	toCreate.Content = ""
+	if conf.Markdown {
+		toCreate.Markdown, toCreate.Keyboard = helpMarkdown(), helpKeyboard()
+	} else {
+		toCreate.Ark = helpArk()
+	}
+}
+
+// rankImage 生成指定范围的今日积分榜图片
+func (p Processor) rankImage(ctx context.Context, scope BoardScope) ([]byte, error) {
+	board, err := p.store.QueryLeaderboard(ctx, scope, startOfDay(time.Now()), time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]RankEntry, 0, len(board))
+	for _, e := range board {
+		entries = append(entries, RankEntry{Rank: e.Rank, Name: e.Name, Score: e.Score})
+	}
+	return RenderRankCard("成语接龙玩家积分榜", time.Now(), entries)
 }
 
-func ShowIdiomTable() string {
+func (p Processor) ShowIdiomTable(ctx context.Context, scope BoardScope) (string, error) {
 	var str = `你好，成语接龙玩家积分榜如下：%s
 	`
-	info := PrintScoreTable()
-	return fmt.Sprintf(str, info)
+	info, err := PrintScoreTable(ctx, p.store, scope)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(str, info), nil
 }
 
-func genReplyidiom(data *Message, input string) string {
+func (p Processor) genReplyidiom(ctx context.Context, data *Message, args *Args) string {
 
 	var str = `你好：
-	即将开始成语接龙，我给出的成语是：%s
-	请继续接龙，给出一个"%c"开头的成语。
-	提示：可以@机器人使用“提示”指令+空格+汉字获取答案！
-	例：@问答机器人提示 足，会给出“足”字开头的四字成语。 
+	即将开始成语接龙（%s模式，%s难度），我给出的成语是：%s
+	请在%s内接龙，给出一个以%s开头的成语。
+	提示：可以@机器人发送“提示”获取本回合的提示，多次提示会依次给出拼音、前两个字和完整答案！
+	发送“结束接龙”可以随时结束本局。
 	`
 
-	// 初始化随机数种子
-	rand.Seed(time.Now().UnixNano())
-	var keys []string
-	for k := range idiomsMap {
-		keys = append(keys, k)
+	options := args.String("选项")
+	if options == "" {
+		options = p.preferredOptions(ctx, data.Author.ID)
 	}
-
-	randomIndex := rand.Intn(len(keys))
-	randomKey := keys[randomIndex]
-	randomValue := idiomsMap[randomKey]
-
-	last, size := utf8.DecodeLastRuneInString(randomValue)
-	IdiomUsers[data.Author.Username] = IdiomUser{
-		score:    0,
-		lastward: last,
+	mode, difficulty, err := parseGameOptions(options)
+	if err != nil {
+		return err.Error()
 	}
-	if IdiomUsers[data.Author.Username].lastward == utf8.RuneError && (size == 0 || size != len(randomValue)) {
-		fmt.Println("字符串为空或不是有效的UTF-8编码")
+	key := gameKeyOf(data)
+	idiom := startIdiom(mode, difficulty)
+	user := &IdiomUser{
+		score:      0,
+		mode:       mode,
+		difficulty: difficulty,
+		lastMsg:    data,
 	}
-	return fmt.Sprintf(str, randomValue, IdiomUsers[data.Author.Username].lastward)
+	user.play(idiom, true)
+	if err := p.games.Create(key, user); errors.Is(err, ErrGameExists) {
+		// 不覆盖进行中的游戏，以免丢掉其中的积分
+		return "你已有进行中的成语接龙，请继续作答，或发送“结束接龙”保存积分后再开始新的一局。"
+	} else if err != nil {
+		log.Println(err)
+		return "抱歉，游戏暂时无法开始，请稍后再试。"
+	}
+	p.resetIdiomTimer(key)
+	return fmt.Sprintf(str, mode, difficulty, idiom.Word, formatDuration(turnTimeout()), user.expect())
+}
+
+// inIdiomGame 消息发送者在当前子频道是否有进行中的成语接龙
+func (p Processor) inIdiomGame(data *Message) bool {
+	_, err := p.games.Get(gameKeyOf(data))
+	return err == nil
 }
 
 // 用户游戏独立，每日计算积分规则
 // 推送每日用户积分
 // sql
-func genAnsweridiom(data *Message, input string) string {
+func (p Processor) genAnsweridiom(data *Message, input string) string {
+	ctx := context.Background()
 	var context = ""
-	firstword, size := utf8.DecodeRuneInString(input)
-	if firstword == utf8.RuneError && (size == 0 || size != len(input)) {
-		fmt.Println("字符串为空或不是有效的UTF-8编码")
+	var next *Idiom
+	var expect string
+	var stuck bool // 机器人给出的成语已无成语可接
+	var turn ScoreBreakdown
+	var streak int
+	var answerErr error
+	key := gameKeyOf(data)
+	err := p.games.Update(key, func(user *IdiomUser) error {
+		idiom, err := checkAnswer(*user, input)
+		if err != nil {
+			// 答错会中断连击，需要保存
+			user.streak = 0
+			answerErr = err
+			return nil
+		}
+		turn = user.answer(idiom)
+		streak = user.streak
+		user.lastMsg = data
+		if next = botMove(user, user.difficulty); next != nil {
+			user.play(next, true)
+			stuck = len(user.next()) == 0
+		}
+		expect = user.expect()
+		// 在游戏锁内开始新的回合，已触发的超时回调确认回合时会发现已过期，不会结束刚答对的游戏
+		if next != nil && !stuck {
+			p.resetIdiomTimer(key)
+		} else {
+			p.timers.stop(key)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNoGame) {
+		return "本局成语接龙已经结束了。"
 	}
-	if IdiomUsers[data.Author.Username].lastward != firstword {
-		context = `你好：
-		你给出的成语不符合成语接龙规则，请给出以"%c"开头的成语:
-		`
-		return fmt.Sprintf(context, IdiomUsers[data.Author.Username].lastward)
-	} else {
-		user := IdiomUsers[data.Author.Username]
-		user.score++
-		IdiomUsers[data.Author.Username] = user
-		fmt.Printf("IdiomUsers[data.Author.Username].score: %v\n", IdiomUsers[data.Author.Username].score)
+	if err != nil {
+		log.Println(err)
+		return "抱歉，答案暂时无法提交，请稍后再试。"
 	}
-
-	inputFirstWord, size := utf8.DecodeLastRuneInString(input)
-	inputLastWord, size := utf8.DecodeLastRuneInString(input)
-	if inputLastWord == utf8.RuneError && (size == 0 || size != len(input)) {
-		fmt.Println("字符串为空或不是有效的UTF-8编码")
+	if answerErr != nil {
+		return answerErr.Error()
 	}
+	achieved := p.achieve(ctx, data.Author.ID, displayName(data),
+		GameEvent{Kind: EventAnswer, Streak: streak, Rare: turn.Rare > 0})
 
-	ans, exists := idiomsMap[string(inputFirstWord)]
-	if exists {
+	if next != nil && stuck {
 		context = `你好：
-		我给出的成语是：%s
-		请继续接龙，给出一个"%c"开头的成语:
+		答对了，本题得%d分！我给出的成语是：%s
+		词库中已没有以%s开头且本局未用过的成语，你被难住了，游戏结束！
+		%s
+		`
+		return fmt.Sprintf(context, turn.Total(), next.Word, expect, p.endIdiomGame(key, nil)) + achieved
+	} else if next != nil {
+		context = `你好：
+		答对了，本题得%d分！我给出的成语是：%s
+		请在%s内接龙，给出一个以%s开头的成语:
 		`
-		user := IdiomUsers[data.Author.Username]
-		user.lastward, size = utf8.DecodeLastRuneInString(ans)
-		if user.lastward == utf8.RuneError && (size == 0 || size != len(ans)) {
-			fmt.Println("字符串为空或不是有效的UTF-8编码")
-		}
-		IdiomUsers[data.Author.Username] = user
 		return fmt.Sprintf(
-			context, ans, IdiomUsers[data.Author.Username].lastward,
-		)
+			context, turn.Total(), next.Word, formatDuration(turnTimeout()), expect,
+		) + achieved
 	} else {
-		context = `词库中未找到一个以"%c"开头的成语，游戏结束！`
-		fmt.Printf("user.score: %v\n", IdiomUsers[data.Author.Username].score)
-		err := UpdateScore(data.Author.Username, IdiomUsers[data.Author.Username].score)
-		delete(idiomsMap, data.Author.Username)
-		if err != nil {
-			fmt.Println("Error inserting user:", err)
+		context = `答对了，本题得%d分！词库中未找到一个以%s开头且本局未用过的成语，游戏结束！
+		%s
+		`
+		return fmt.Sprintf(context, turn.Total(), expect, p.endIdiomGame(key, nil)) + achieved
+	}
+
+}
+
+// endIdiomGame 结束玩家的成语接龙：停止计时、保存积分并返回本局小结，没有结束游戏时返回空
+// check 不为 nil 时在游戏锁内检查能否结束，返回错误时不结束游戏
+func (p Processor) endIdiomGame(key GameKey, check func(user *IdiomUser) error) string {
+	ctx := context.Background()
+	user, err := p.games.Finish(key, check)
+	if errors.Is(err, errStaleTurn) {
+		return ""
+	}
+	p.timers.stop(key)
+	if err != nil {
+		if !errors.Is(err, ErrNoGame) {
+			log.Println(err)
+		}
+		return ""
+	}
+	// 游戏确实结束后才结算，结束失败时游戏仍在进行，之后再结束不会重复计分
+	// 结算按每日上限扣减，把实际计入的得分补进上一局，接龙记录中显示的与积分榜一致
+	user.breakdown = p.settleScore(ctx, scoreRecordOf(user.lastMsg, 0), user.breakdown)
+	user.score = user.breakdown.Total()
+	if err := p.games.SetLast(key, user); err != nil {
+		log.Println(err)
+	}
+	if err := p.store.RecordGame(ctx, key.UserID, user.bestStreak); err != nil {
+		log.Println(err)
+	}
+	achieved := p.achieve(ctx, key.UserID, displayName(user.lastMsg), GameEvent{Kind: EventGameOver, Streak: user.bestStreak})
+	return fmt.Sprintf("%s\n接龙记录：%s%s", user.summary(), user.chainText(), achieved)
+}
+
+// resetIdiomTimer 玩家的新回合开始计时
+func (p Processor) resetIdiomTimer(key GameKey) {
+	p.timers.reset(key, turnTimeout(), conf.Game.RemindBefore,
+		func(turn gameTurn) { p.idiomRemind(key, turn) },
+		func(turn gameTurn) { p.idiomTimeout(key, turn) },
+	)
+}
+
+// restoreGames 重启后为进行中的游戏重新计时
+func (p Processor) restoreGames() {
+	games, err := p.games.All()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for key := range games {
+		p.resetIdiomTimer(key)
+	}
+	if len(games) > 0 {
+		log.Printf("%d idiom games restored\n", len(games))
+	}
+}
+
+// idiomRemind 回合即将超时时提醒玩家
+func (p Processor) idiomRemind(key GameKey, turn gameTurn) {
+	if !p.timers.active(key, turn) {
+		return
+	}
+	user, err := p.games.Get(key)
+	if err != nil {
+		return
+	}
+	p.notify(user.lastMsg, fmt.Sprintf("<@%s>还剩%s！请给出一个以%s开头的成语，发送“结束接龙”可以结束本局。",
+		key.UserID, formatDuration(conf.Game.RemindBefore), user.expect()))
+}
+
+// idiomTimeout 回合超时，结束游戏并保存积分
+func (p Processor) idiomTimeout(key GameKey, turn gameTurn) {
+	var data *Message
+	summary := p.endIdiomGame(key, func(user *IdiomUser) error {
+		// 超时回调触发后玩家仍可能抢先答对，在游戏锁内再确认一次回合
+		if !p.timers.active(key, turn) {
+			return errStaleTurn
 		}
-		return fmt.Sprintf(context, inputLastWord)
+		data = user.lastMsg
+		return nil
+	})
+	if summary == "" {
+		return
 	}
+	p.notify(data, fmt.Sprintf("<@%s>%s内没有接上，游戏结束！\n%s",
+		key.UserID, formatDuration(turnTimeout()), summary))
+}
 
+// stopIdiom 结束接龙：优先结束自己的游戏，否则结束子频道内由自己发起的多人接龙
+func (p Processor) stopIdiom(data *Message) string {
+	if summary := p.endIdiomGame(gameKeyOf(data), nil); summary != "" {
+		return "你结束了本局成语接龙。\n" + summary
+	}
+	if content, ok := p.stopRelay(data); ok {
+		return content
+	}
+	return "你当前没有进行中的成语接龙。"
 }
 
-func hintIdiom(data *Message, input string) string {
-	text := strings.Split(input, " ")
-	word := text[1]
+// hint 有进行中的游戏时给出本回合的渐进提示，否则按指定的汉字查询成语
+func (p Processor) hint(data *Message, args *Args) string {
+	var content string
+	err := p.games.Update(gameKeyOf(data), func(user *IdiomUser) error {
+		var err error
+		content, err = user.nextHint(conf.Game.HintBudget)
+		return err
+	})
+	switch {
+	case err == nil:
+		return content
+	case errors.Is(err, ErrHintBudget):
+		return fmt.Sprintf("本局的%d次提示已经用完了，靠自己想想吧！", conf.Game.HintBudget)
+	case errors.Is(err, ErrNoHint):
+		return "词库中已经没有可以接的成语了，发送“结束接龙”结束本局吧。"
+	case !errors.Is(err, ErrNoGame):
+		log.Println(err)
+		return "抱歉，提示暂时无法获取，请稍后再试。"
+	}
+	if p.inRelay(data.ChannelID) {
+		return "多人接龙中不能使用提示哦。"
+	}
+	if !args.Has("汉字") {
+		return "你当前没有进行中的成语接龙，请在“提示”后加上一个汉字，如：提示 人"
+	}
+	return hintIdiom(data, args)
+}
 
-	idiom, exists := idiomsMap[word]
-	if exists {
+// chainRecord 当前一局的接龙记录，没有进行中的游戏时返回上一局的记录
+func (p Processor) chainRecord(data *Message) string {
+	key := gameKeyOf(data)
+	if user, err := p.games.Get(key); err == nil {
+		return fmt.Sprintf("你好，当前这局的接龙记录：\n%s\n共%d个成语，当前得分%d。", user.chainText(), len(user.chain), user.score)
+	}
+	if user, err := p.games.Last(key); err == nil {
+		return fmt.Sprintf("你好，上一局的接龙记录：\n%s\n%s", user.chainText(), user.summary())
+	}
+	return "你还没有玩过成语接龙，@机器人发送“成语接龙”开始游戏吧！"
+}
+
+func hintIdiom(data *Message, args *Args) string {
+	word := string(args.Rune("汉字"))
+
+	idioms := sampleIdioms(idiomDict.StartingWith(args.Rune("汉字")), 3)
+	if len(idioms) > 0 {
 		var str = `你好,以"%s"开头的成语有：%s
 		`
+		words := make([]string, 0, len(idioms))
+		for _, idiom := range idioms {
+			if idiom.Explanation != "" {
+				words = append(words, fmt.Sprintf("\n%s：%s", idiom.Word, idiom.Explanation))
+			} else {
+				words = append(words, "\n"+idiom.Word)
+			}
+		}
 		return fmt.Sprintf(
-			str, word, idiom,
+			str, word, strings.Join(words, ""),
 		)
 	} else {
 		var str = `抱歉，词库中未找到以"%s"开头的成语。`
@@ -229,9 +600,8 @@ func hintIdiom(data *Message, input string) string {
 	}
 }
 
-func genReplyContent(data *Message, input string) string {
-	text := strings.Split(input, " ")
-	formula := text[1]
+func genReplyContent(data *Message, args *Args) string {
+	formula := args.String("算式")
 
 	var str = `你好：
 	当前本地时间为：%s
@@ -245,8 +615,8 @@ func genReplyContent(data *Message, input string) string {
 			getIP(),
 		)
 	}
-	num1, err1 := strconv.Atoi(parts[0])
-	num2, err2 := strconv.Atoi(parts[1])
+	num1, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+	num2, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
 	if err1 != nil || err2 != nil {
 		return fmt.Sprintf(
 			str, time.Now().Format(time.RFC3339),
@@ -267,21 +637,58 @@ func genReplyContent(data *Message, input string) string {
 	)
 }
 
+// pushTarget 把一个推送目标所属频道的今日积分榜发到该子频道
+func (p Processor) pushTarget(ctx context.Context, target PushTarget) {
+	scope := BoardScope{GuildID: target.GuildID}
+	if scope.GuildID == "" {
+		guildID, err := p.store.GuildOfChannel(ctx, target.ChannelID)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if guildID == "" {
+			log.Printf("子频道 %s 还没有积分记录，无法确定所属频道，跳过推送", target.ChannelID)
+			return
+		}
+		scope.GuildID = guildID
+	}
+	if target.ChannelOnly {
+		scope.ChannelID = target.ChannelID
+	}
+	toCreate := &MessageToCreate{
+		Content: "今日成语接龙积分榜"}
+	if image, err := p.rankImage(ctx, scope); err != nil {
+		log.Println(err)
+		if toCreate.Content, err = p.ShowIdiomTable(ctx, scope); err != nil {
+			log.Println(err)
+			return
+		}
+		if _, err := p.api.PostMessage(ctx, target.ChannelID, toCreate); err != nil {
+			log.Println(err)
+		}
+	} else if _, err := p.api.PostMessageImage(ctx, target.ChannelID, toCreate, "rank.png", image); err != nil {
+		log.Println(err)
+	}
+}
+
 func (p Processor) DailyPush() {
+	if conf.DailyPush.Time == "" || len(conf.DailyPush.Targets) == 0 {
+		return
+	}
+	pushHour, pushMinute, err := conf.DailyPush.at()
+	if err != nil {
+		log.Printf("每日推送时间 %q 格式错误，应为 15:04：%v", conf.DailyPush.Time, err)
+		return
+	}
 	var printed18 int32
 	checkAndPrint18 := func() {
 		now := time.Now()
 		hour, minute := now.Hour(), now.Minute()
-		if hour == 16 && minute == 11 && atomic.CompareAndSwapInt32(&printed18, 0, 1) {
-			fmt.Println("打印！！！")
+		if hour == pushHour && minute == pushMinute && atomic.CompareAndSwapInt32(&printed18, 0, 1) {
 			ctx := context.Background()
-			toCreate := &MessageToCreate{
-				Content: "默认回复"}
-			toCreate.Content = ShowIdiomTable()
-			if _, err := p.api.PostMessage(ctx, "634993940", toCreate); err != nil {
-				log.Println(err)
+			for _, target := range conf.DailyPush.Targets {
+				p.pushTarget(ctx, target)
 			}
-			DeleteTable()
 		}
 	}
 
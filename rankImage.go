@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hajimehoshi/bitmapfont/v3"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// RankEntry 榜单中的一行
+type RankEntry struct {
+	Rank  int
+	Name  string
+	Score int
+}
+
+// 卡片按 1 倍尺寸绘制，最后整体放大，点阵字体放大后依然清晰
+const (
+	rankCardScale     = 3
+	rankCardWidth     = 220
+	rankCardHeaderH   = 44
+	rankCardRowH      = 22
+	rankCardFooterH   = 18
+	rankCardPadding   = 10
+	rankCardAvatarR   = 8
+	rankCardMaxRows   = 20
+	rankCardNameWidth = 120
+)
+
+var (
+	rankCardBackground = color.RGBA{0xFF, 0xF8, 0xE7, 0xFF}
+	rankCardHeaderBg   = color.RGBA{0xC6, 0x3C, 0x2F, 0xFF}
+	rankCardStripe     = color.RGBA{0xF6, 0xEC, 0xD4, 0xFF}
+	rankCardText       = color.RGBA{0x33, 0x2A, 0x22, 0xFF}
+	rankCardSubText    = color.RGBA{0x8A, 0x7B, 0x6A, 0xFF}
+	rankCardAvatarBg   = color.RGBA{0xD8, 0xC9, 0xB0, 0xFF}
+	// 前三名的名次颜色：金、银、铜
+	rankCardMedals = []color.RGBA{
+		{0xE0, 0xA8, 0x00, 0xFF},
+		{0x9E, 0xA7, 0xB0, 0xFF},
+		{0xB8, 0x73, 0x33, 0xFF},
+	}
+)
+
+// rankFace 内置的点阵中文字体，12px
+var rankFace = bitmapfont.FaceSC
+
+// RenderRankCard 绘制积分榜卡片，返回 PNG 数据
+// 超过 rankCardMaxRows 的部分不绘制，由调用方决定是否另行提示
+func RenderRankCard(title string, date time.Time, entries []RankEntry) ([]byte, error) {
+	if len(entries) > rankCardMaxRows {
+		entries = entries[:rankCardMaxRows]
+	}
+	rows := len(entries)
+	if rows == 0 {
+		rows = 1 // 留一行显示空榜提示
+	}
+	height := rankCardHeaderH + rows*rankCardRowH + rankCardFooterH
+	card := image.NewRGBA(image.Rect(0, 0, rankCardWidth, height))
+	fill(card, card.Bounds(), rankCardBackground)
+
+	// 标题栏
+	fill(card, image.Rect(0, 0, rankCardWidth, rankCardHeaderH), rankCardHeaderBg)
+	drawString(card, rankCardPadding, 18, title, color.White)
+	drawString(card, rankCardPadding, 36, date.Format("2006-01-02"), rankCardStripe)
+
+	if len(entries) == 0 {
+		drawString(card, rankCardPadding, rankCardHeaderH+15, "暂无玩家上榜", rankCardSubText)
+	}
+	for i, e := range entries {
+		top := rankCardHeaderH + i*rankCardRowH
+		if i%2 == 1 {
+			fill(card, image.Rect(0, top, rankCardWidth, top+rankCardRowH), rankCardStripe)
+		}
+		baseline := top + 15
+
+		// 名次，前三名使用奖牌色
+		rankColor := rankCardText
+		if e.Rank >= 1 && e.Rank <= len(rankCardMedals) {
+			rankColor = rankCardMedals[e.Rank-1]
+		}
+		drawString(card, rankCardPadding, baseline, strconv.Itoa(e.Rank), rankColor)
+
+		// 头像占位：圆形底色 + 名字首字
+		cx, cy := rankCardPadding+30, top+rankCardRowH/2
+		fillCircle(card, cx, cy, rankCardAvatarR, rankCardAvatarBg)
+		if r, _ := utf8.DecodeRuneInString(e.Name); r != utf8.RuneError {
+			w := font.MeasureString(rankFace, string(r)).Ceil()
+			drawString(card, cx-w/2, baseline, string(r), rankCardText)
+		}
+
+		drawString(card, cx+rankCardAvatarR+6, baseline, truncateToWidth(e.Name, rankCardNameWidth), rankCardText)
+
+		score := fmt.Sprintf("%d分", e.Score)
+		w := font.MeasureString(rankFace, score).Ceil()
+		drawString(card, rankCardWidth-rankCardPadding-w, baseline, score, rankCardText)
+	}
+
+	footer := "成语接龙 · 每日积分榜"
+	w := font.MeasureString(rankFace, footer).Ceil()
+	drawString(card, (rankCardWidth-w)/2, height-5, footer, rankCardSubText)
+
+	scaled := image.NewRGBA(image.Rect(0, 0, rankCardWidth*rankCardScale, height*rankCardScale))
+	xdraw.NearestNeighbor.Scale(scaled, scaled.Bounds(), card, card.Bounds(), draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scaled); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fill(dst draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(dst, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func fillCircle(dst draw.Image, cx, cy, r int, c color.Color) {
+	for y := -r; y <= r; y++ {
+		for x := -r; x <= r; x++ {
+			if x*x+y*y <= r*r {
+				dst.Set(cx+x, cy+y, c)
+			}
+		}
+	}
+}
+
+// drawString 以 (x, baseline) 为起点绘制文字
+func drawString(dst draw.Image, x, baseline int, s string, c color.Color) {
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: rankFace,
+		Dot:  fixed.P(x, baseline),
+	}
+	d.DrawString(s)
+}
+
+// truncateToWidth 截断过长的文字，以省略号结尾
+func truncateToWidth(s string, width int) string {
+	if font.MeasureString(rankFace, s).Ceil() <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		t := string(runes) + "…"
+		if font.MeasureString(rankFace, t).Ceil() <= width {
+			return t
+		}
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderRankCard(t *testing.T) {
+	entries := func(n int) []RankEntry {
+		var list []RankEntry
+		for i := 1; i <= n; i++ {
+			list = append(list, RankEntry{Rank: i, Name: fmt.Sprintf("玩家%d号的名字很长很长很长很长", i), Score: 100 - i})
+		}
+		return list
+	}
+	for _, c := range []struct {
+		entries int
+		rows    int
+	}{
+		{entries: 0, rows: 1}, // 空榜留一行提示
+		{entries: 1, rows: 1},
+		{entries: 25, rows: rankCardMaxRows},
+	} {
+		data, err := RenderRankCard("成语接龙玩家积分榜", time.Now(), entries(c.entries))
+		if err != nil {
+			t.Fatalf("%d entries: %v", c.entries, err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%d entries: invalid png: %v", c.entries, err)
+		}
+		height := rankCardHeaderH + c.rows*rankCardRowH + rankCardFooterH
+		if size := img.Bounds().Size(); size.X != rankCardWidth*rankCardScale || size.Y != height*rankCardScale {
+			t.Errorf("%d entries: card is %v, want %dx%d", c.entries, size, rankCardWidth*rankCardScale, height*rankCardScale)
+		}
+	}
+}
+
+func TestTruncateToWidth(t *testing.T) {
+	if got := truncateToWidth("小明", rankCardNameWidth); got != "小明" {
+		t.Errorf("short name truncated to %q", got)
+	}
+	long := "一二三四五六七八九十一二三四五六七八九十"
+	got := truncateToWidth(long, rankCardNameWidth)
+	if got == long || !strings.HasSuffix(got, "…") {
+		t.Errorf("long name = %q, want truncated with ellipsis", got)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// RelayStore 多人接龙的游戏状态存储，每个子频道同时只有一局，实现需要保证并发安全
+type RelayStore interface {
+	// Create 在子频道开始一局多人接龙，子频道已有进行中的多人接龙时不覆盖，返回 ErrGameExists
+	Create(channelID string, game *RelayGame) error
+	// Update 在锁内修改进行中的多人接龙并保存，fn 返回错误时不保存，游戏不存在时返回 ErrNoGame
+	Update(channelID string, fn func(game *RelayGame) error) error
+	// Get 进行中的多人接龙，游戏不存在时返回 ErrNoGame
+	Get(channelID string) (*RelayGame, error)
+	// Finish 结束进行中的多人接龙，返回结束时的状态
+	// fn 不为 nil 时先在锁内检查结束时的状态，返回错误时不结束游戏并返回该错误
+	Finish(channelID string, fn func(game *RelayGame) error) (*RelayGame, error)
+	// All 全部进行中的多人接龙，用于重启后恢复计时
+	All() (map[string]*RelayGame, error)
+}
+
+// NewRelayStore 按配置创建多人接龙的状态存储，与 NewGameStore 使用同一个配置项
+func NewRelayStore(kind string, store Storage) (RelayStore, error) {
+	switch kind {
+	case "", "memory":
+		return NewMemoryRelayStore(), nil
+	case "database":
+		return store.RelayStore(), nil
+	default:
+		return nil, errors.New("unsupported game store: " + kind)
+	}
+}
+
+// MemoryRelayStore 内存存储，重启后游戏状态丢失；多人接龙按子频道只有少量几局，一把锁即可
+type MemoryRelayStore struct {
+	mu    sync.Mutex
+	games map[string]*RelayGame
+}
+
+// NewMemoryRelayStore 创建内存存储
+func NewMemoryRelayStore() *MemoryRelayStore {
+	return &MemoryRelayStore{games: map[string]*RelayGame{}}
+}
+
+// Create 开始一局多人接龙
+func (s *MemoryRelayStore) Create(channelID string, game *RelayGame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.games[channelID]; ok {
+		return ErrGameExists
+	}
+	s.games[channelID] = game.clone()
+	return nil
+}
+
+// Update 在锁内修改进行中的多人接龙
+func (s *MemoryRelayStore) Update(channelID string, fn func(game *RelayGame) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	game, ok := s.games[channelID]
+	if !ok {
+		return ErrNoGame
+	}
+	updated := game.clone()
+	if err := fn(updated); err != nil {
+		return err
+	}
+	s.games[channelID] = updated
+	return nil
+}
+
+// Get 进行中的多人接龙
+func (s *MemoryRelayStore) Get(channelID string) (*RelayGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	game, ok := s.games[channelID]
+	if !ok {
+		return nil, ErrNoGame
+	}
+	return game.clone(), nil
+}
+
+// Finish 结束进行中的多人接龙
+func (s *MemoryRelayStore) Finish(channelID string, fn func(game *RelayGame) error) (*RelayGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	game, ok := s.games[channelID]
+	if !ok {
+		return nil, ErrNoGame
+	}
+	if fn != nil {
+		if err := fn(game.clone()); err != nil {
+			return nil, err
+		}
+	}
+	delete(s.games, channelID)
+	return game, nil
+}
+
+// All 全部进行中的多人接龙
+func (s *MemoryRelayStore) All() (map[string]*RelayGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := make(map[string]*RelayGame, len(s.games))
+	for channelID, game := range s.games {
+		all[channelID] = game.clone()
+	}
+	return all, nil
+}
+
+// clone 深拷贝多人接龙的状态，存储内外的修改互不影响
+func (g *RelayGame) clone() *RelayGame {
+	c := *g
+	c.IdiomUser = *g.IdiomUser.clone()
+	c.players = make([]*RelayPlayer, 0, len(g.players))
+	for _, pl := range g.players {
+		p := *pl
+		c.players = append(c.players, &p)
+	}
+	c.scores = make(map[string]*ScoreBreakdown, len(g.scores))
+	for id, b := range g.scores {
+		score := *b
+		c.scores[id] = &score
+	}
+	return &c
+}
+
+// relayState 多人接龙的序列化格式，接龙状态沿用单人游戏的格式
+type relayState struct {
+	Game      *IdiomUser                 `json:"game"`
+	ChannelID string                     `json:"channel_id"`
+	Rotate    bool                       `json:"rotate"`
+	Timeout   time.Duration              `json:"timeout"`
+	Players   []*RelayPlayer             `json:"players"`
+	Scores    map[string]*ScoreBreakdown `json:"scores"`
+	Turn      int                        `json:"turn"`
+	Misses    int                        `json:"misses"`
+}
+
+// MarshalJSON 序列化多人接龙的状态，不能沿用嵌入的 IdiomUser 的方法，否则会丢掉玩家与得分
+func (g *RelayGame) MarshalJSON() ([]byte, error) {
+	return json.Marshal(relayState{
+		Game:      &g.IdiomUser,
+		ChannelID: g.ChannelID,
+		Rotate:    g.Rotate,
+		Timeout:   g.Timeout,
+		Players:   g.players,
+		Scores:    g.scores,
+		Turn:      g.turn,
+		Misses:    g.misses,
+	})
+}
+
+// UnmarshalJSON 反序列化多人接龙的状态
+func (g *RelayGame) UnmarshalJSON(b []byte) error {
+	s := relayState{Game: &IdiomUser{}}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*g = RelayGame{
+		IdiomUser: *s.Game,
+		ChannelID: s.ChannelID,
+		Rotate:    s.Rotate,
+		Timeout:   s.Timeout,
+		players:   s.Players,
+		scores:    s.Scores,
+		turn:      s.Turn,
+		misses:    s.Misses,
+	}
+	if g.scores == nil {
+		g.scores = map[string]*ScoreBreakdown{}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// SQLRelayStore 保存在数据库中的多人接龙，重启后进行中的多人接龙可以继续
+// 每局的状态以 JSON 保存，以子频道ID为主键；所有读写都在 mu 内串行进行，保证 Update 读改写的原子性
+type SQLRelayStore struct {
+	mu      sync.Mutex
+	db      *sql.DB
+	dialect dialect
+}
+
+// NewSQLRelayStore 使用已打开的数据库保存多人接龙，数据表由迁移脚本创建，数据库由调用方负责关闭
+func NewSQLRelayStore(db *sql.DB, d dialect) *SQLRelayStore {
+	return &SQLRelayStore{db: db, dialect: d}
+}
+
+func (s *SQLRelayStore) load(channelID string) (*RelayGame, error) {
+	var state string
+	err := s.db.QueryRow(s.dialect.rebind("SELECT state FROM relay_games WHERE channel_id = ?"), channelID).Scan(&state)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoGame
+	}
+	if err != nil {
+		return nil, err
+	}
+	game := &RelayGame{}
+	if err := json.Unmarshal([]byte(state), game); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+// Create 开始一局多人接龙
+func (s *SQLRelayStore) Create(channelID string, game *RelayGame) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, err := json.Marshal(game)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(s.dialect.rebind(
+		"INSERT INTO relay_games (channel_id, state, updated_at) VALUES (?, ?, ?) ON CONFLICT (channel_id) DO NOTHING;"),
+		channelID, string(state), time.Now().Unix(),
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = ErrGameExists
+		}
+		return err
+	}
+	return nil
+}
+
+// Update 在锁内修改进行中的多人接龙
+func (s *SQLRelayStore) Update(channelID string, fn func(game *RelayGame) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	game, err := s.load(channelID)
+	if err != nil {
+		return err
+	}
+	if err := fn(game); err != nil {
+		return err
+	}
+	state, err := json.Marshal(game)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.dialect.rebind("UPDATE relay_games SET state = ?, updated_at = ? WHERE channel_id = ?"),
+		string(state), time.Now().Unix(), channelID,
+	)
+	return err
+}
+
+// Get 进行中的多人接龙
+func (s *SQLRelayStore) Get(channelID string) (*RelayGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(channelID)
+}
+
+// Finish 结束进行中的多人接龙
+func (s *SQLRelayStore) Finish(channelID string, fn func(game *RelayGame) error) (*RelayGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	game, err := s.load(channelID)
+	if err != nil {
+		return nil, err
+	}
+	if fn != nil {
+		if err := fn(game.clone()); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := s.db.Exec(s.dialect.rebind("DELETE FROM relay_games WHERE channel_id = ?"), channelID); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+// All 全部进行中的多人接龙
+func (s *SQLRelayStore) All() (map[string]*RelayGame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rows, err := s.db.Query("SELECT channel_id, state FROM relay_games")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := map[string]*RelayGame{}
+	for rows.Next() {
+		var channelID, state string
+		if err := rows.Scan(&channelID, &state); err != nil {
+			return nil, err
+		}
+		game := &RelayGame{}
+		if err := json.Unmarshal([]byte(state), game); err != nil {
+			return nil, err
+		}
+		all[channelID] = game
+	}
+	return all, rows.Err()
+}
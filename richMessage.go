@@ -0,0 +1,265 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Embed 结构
+type Embed struct {
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Prompt      string                 `json:"prompt"` // 消息弹窗内容，消息列表摘要
+	Thumbnail   *MessageEmbedThumbnail `json:"thumbnail,omitempty"`
+	Fields      []*EmbedField          `json:"fields,omitempty"`
+}
+
+// MessageEmbedThumbnail embed 消息的缩略图对象
+type MessageEmbedThumbnail struct {
+	URL string `json:"url"`
+}
+
+// EmbedField Embed字段描述
+type EmbedField struct {
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// NewEmbed 创建 embed 消息，prompt 为消息列表中显示的摘要
+func NewEmbed(title, prompt string) *Embed {
+	return &Embed{Title: title, Prompt: prompt}
+}
+
+// WithDescription 设置描述
+func (e *Embed) WithDescription(desc string) *Embed {
+	e.Description = desc
+	return e
+}
+
+// WithThumbnail 设置缩略图
+func (e *Embed) WithThumbnail(url string) *Embed {
+	e.Thumbnail = &MessageEmbedThumbnail{URL: url}
+	return e
+}
+
+// AddField 追加一行字段
+func (e *Embed) AddField(name, value string) *Embed {
+	e.Fields = append(e.Fields, &EmbedField{Name: name, Value: value})
+	return e
+}
+
+// Ark 消息模版
+type Ark struct {
+	TemplateID int      `json:"template_id,omitempty"` // ark模版 ID
+	KV         []*ArkKV `json:"kv,omitempty"`          // ArkKV 数组
+}
+
+// ArkKV Ark 键值对结构体
+type ArkKV struct {
+	Key   string    `json:"key,omitempty"`
+	Value string    `json:"value,omitempty"`
+	Obj   []*ArkObj `json:"obj,omitempty"`
+}
+
+// ArkObj Ark 对象类型
+type ArkObj struct {
+	ObjKV []*ArkObjKV `json:"obj_kv,omitempty"`
+}
+
+// ArkObjKV Ark 对象键值对
+type ArkObjKV struct {
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// 官方提供的 ark 模版
+const (
+	ArkTemplateLinkList = 23 // 链接+文本列表模板
+	ArkTemplateTextImg  = 24 // 文本+缩略图模板
+	ArkTemplateBigImg   = 37 // 大图模板
+)
+
+// NewArk 创建 ark 消息
+func NewArk(templateID int) *Ark {
+	return &Ark{TemplateID: templateID}
+}
+
+// AddKV 追加一个键值
+func (a *Ark) AddKV(key, value string) *Ark {
+	a.KV = append(a.KV, &ArkKV{Key: key, Value: value})
+	return a
+}
+
+// AddObjList 追加一个对象列表，每个对象由若干键值组成
+func (a *Ark) AddObjList(key string, objs ...*ArkObj) *Ark {
+	a.KV = append(a.KV, &ArkKV{Key: key, Obj: objs})
+	return a
+}
+
+// NewArkObj 创建 ark 对象，kv 依次为 key、value
+func NewArkObj(kv ...string) *ArkObj {
+	obj := &ArkObj{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		obj.ObjKV = append(obj.ObjKV, &ArkObjKV{Key: kv[i], Value: kv[i+1]})
+	}
+	return obj
+}
+
+// Markdown markdown 消息
+type Markdown struct {
+	TemplateID       int               `json:"template_id,omitempty"`        // 模版 id，与 CustomTemplateID 二选一
+	CustomTemplateID string            `json:"custom_template_id,omitempty"` // 自定义模版 id
+	Params           []*MarkdownParams `json:"params,omitempty"`             // 模版参数
+	Content          string            `json:"content,omitempty"`            // 原生 markdown，与模版二选一
+}
+
+// MarkdownParams markdown 模版参数 键值对
+type MarkdownParams struct {
+	Key    string   `json:"key"`
+	Values []string `json:"values"`
+}
+
+// NewMarkdownTemplate 创建使用自定义模版的 markdown 消息
+func NewMarkdownTemplate(customTemplateID string) *Markdown {
+	return &Markdown{CustomTemplateID: customTemplateID}
+}
+
+// NewMarkdown 创建原生 markdown 消息
+func NewMarkdown(content string) *Markdown {
+	return &Markdown{Content: content}
+}
+
+// AddParam 追加模版参数
+func (m *Markdown) AddParam(key string, values ...string) *Markdown {
+	m.Params = append(m.Params, &MarkdownParams{Key: key, Values: values})
+	return m
+}
+
+// markdownEscaper 转义 markdown 中有特殊含义的字符
+var markdownEscaper = strings.NewReplacer(
+	"\\", "\\\\", "*", "\\*", "_", "\\_", "`", "\\`", "~", "\\~",
+	"[", "\\[", "]", "\\]", "#", "\\#", "<", "\\<", ">", "\\>",
+)
+
+// EscapeMarkdown 转义玩家名字等外部文本，避免被当作 markdown 语法
+func EscapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// MessageKeyboard 消息按钮组件
+type MessageKeyboard struct {
+	ID      string          `json:"id,omitempty"`      // 消息按钮组件模板 ID
+	Content *CustomKeyboard `json:"content,omitempty"` // 消息按钮组件自定义内容
+}
+
+// CustomKeyboard 自定义 Keyboard
+type CustomKeyboard struct {
+	Rows []*KeyboardRow `json:"rows,omitempty"` // 行数组
+}
+
+// KeyboardRow 每行结构
+type KeyboardRow struct {
+	Buttons []*Button `json:"buttons,omitempty"` // 每行按钮
+}
+
+// Button 单个按纽
+type Button struct {
+	ID         string            `json:"id,omitempty"`          // 按钮 ID
+	RenderData *ButtonRenderData `json:"render_data,omitempty"` // 渲染展示字段
+	Action     *ButtonAction     `json:"action,omitempty"`      // 该按纽操作相关字段
+}
+
+// ButtonRenderData 按纽渲染展示
+type ButtonRenderData struct {
+	Label        string      `json:"label,omitempty"`         // 按纽上的文字
+	VisitedLabel string      `json:"visited_label,omitempty"` // 点击后按纽上文字
+	Style        ButtonStyle `json:"style,omitempty"`         // 按钮样式
+}
+
+// ButtonStyle 按钮样式
+type ButtonStyle uint32
+
+// 按钮样式
+const (
+	ButtonStyleGrey ButtonStyle = iota // 灰色线框
+	ButtonStyleBlue                    // 蓝色线框
+)
+
+// ButtonActionType 按钮操作类型
+type ButtonActionType uint32
+
+// 按钮操作类型
+const (
+	ButtonActionJump     ButtonActionType = iota // 跳转按钮
+	ButtonActionCallback                         // 回调按钮
+	ButtonActionCommand                          // 指令按钮
+)
+
+// ButtonAction 按纽点击操作
+type ButtonAction struct {
+	Type                 ButtonActionType  `json:"type"`                               // 操作类型
+	Permission           *ButtonPermission `json:"permission,omitempty"`               // 可操作
+	ClickLimit           uint32            `json:"click_limit,omitempty"`              // 可点击的次数, 默认不限
+	Data                 string            `json:"data,omitempty"`                     // 操作相关数据
+	Enter                bool              `json:"enter,omitempty"`                    // 指令按钮是否直接发送
+	AtBotShowChannelList bool              `json:"at_bot_show_channel_list,omitempty"` // false:当前 true:弹出展示子频道选择器
+}
+
+// ButtonPermissionType 按钮的权限类型
+type ButtonPermissionType uint32
+
+// 按钮的权限类型
+const (
+	ButtonPermissionSpecifyUser ButtonPermissionType = iota // 仅指定这条消息的人可操作
+	ButtonPermissionManager                                 // 仅频道管理者可操作
+	ButtonPermissionAll                                     // 所有人可操作
+	ButtonPermissionSpecifyRole                             // 指定身份组可操作
+)
+
+// ButtonPermission 按纽操作权限
+type ButtonPermission struct {
+	Type           ButtonPermissionType `json:"type"`                       // 操作权限类型
+	SpecifyRoleIDs []string             `json:"specify_role_ids,omitempty"` // 身份组
+	SpecifyUserIDs []string             `json:"specify_user_ids,omitempty"` // 指定 UserID
+}
+
+// NewKeyboard 创建自定义按钮组件
+func NewKeyboard() *MessageKeyboard {
+	return &MessageKeyboard{Content: &CustomKeyboard{}}
+}
+
+// AddRow 追加一行按钮，按钮 ID 未设置时按位置自动生成
+func (k *MessageKeyboard) AddRow(buttons ...*Button) *MessageKeyboard {
+	for i, b := range buttons {
+		if b.ID == "" {
+			b.ID = strconv.Itoa(len(k.Content.Rows)) + "-" + strconv.Itoa(i)
+		}
+	}
+	k.Content.Rows = append(k.Content.Rows, &KeyboardRow{Buttons: buttons})
+	return k
+}
+
+// CommandButton 所有人可点击的指令按钮，点击后在输入框填入 @机器人 + command
+func CommandButton(label, command string, enter bool) *Button {
+	return &Button{
+		RenderData: &ButtonRenderData{Label: label, VisitedLabel: label, Style: ButtonStyleBlue},
+		Action: &ButtonAction{
+			Type:       ButtonActionCommand,
+			Permission: &ButtonPermission{Type: ButtonPermissionAll},
+			Data:       command,
+			Enter:      enter,
+		},
+	}
+}
+
+// LinkButton 所有人可点击的跳转按钮
+func LinkButton(label, url string) *Button {
+	return &Button{
+		RenderData: &ButtonRenderData{Label: label, VisitedLabel: label, Style: ButtonStyleGrey},
+		Action: &ButtonAction{
+			Type:       ButtonActionJump,
+			Permission: &ButtonPermission{Type: ButtonPermissionAll},
+			Data:       url,
+		},
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRichMessageJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  interface{}
+		want string
+	}{
+		{
+			name: "embed without thumbnail",
+			msg:  NewEmbed("积分榜", "积分榜").AddField("第1名  小明  10分", ""),
+			want: `{"title":"积分榜","prompt":"积分榜","fields":[{"name":"第1名  小明  10分"}]}`,
+		},
+		{
+			name: "embed with thumbnail",
+			msg:  NewEmbed("标题", "摘要").WithDescription("描述").WithThumbnail("https://example.com/a.png"),
+			want: `{"title":"标题","description":"描述","prompt":"摘要","thumbnail":{"url":"https://example.com/a.png"}}`,
+		},
+		{
+			name: "ark",
+			msg: NewArk(ArkTemplateLinkList).
+				AddKV("#DESC#", "说明").
+				AddObjList("#LIST#", NewArkObj("desc", "第一行"), NewArkObj("desc", "第二行", "link", "https://example.com")),
+			want: `{"template_id":23,"kv":[{"key":"#DESC#","value":"说明"},{"key":"#LIST#","obj":[` +
+				`{"obj_kv":[{"key":"desc","value":"第一行"}]},` +
+				`{"obj_kv":[{"key":"desc","value":"第二行"},{"key":"link","value":"https://example.com"}]}]}]}`,
+		},
+		{
+			name: "native markdown",
+			msg:  NewMarkdown("# 标题"),
+			want: `{"content":"# 标题"}`,
+		},
+		{
+			name: "markdown template",
+			msg:  NewMarkdownTemplate("101_1").AddParam("title", "积分榜").AddParam("rows", "小明", "小红"),
+			want: `{"custom_template_id":"101_1","params":[{"key":"title","values":["积分榜"]},{"key":"rows","values":["小明","小红"]}]}`,
+		},
+		{
+			name: "keyboard",
+			msg:  NewKeyboard().AddRow(CommandButton("今日", "积分榜 今日", true), LinkButton("文档", "https://example.com")),
+			want: `{"content":{"rows":[{"buttons":[` +
+				`{"id":"0-0","render_data":{"label":"今日","visited_label":"今日","style":1},` +
+				`"action":{"type":2,"permission":{"type":2},"data":"积分榜 今日","enter":true}},` +
+				`{"id":"0-1","render_data":{"label":"文档","visited_label":"文档"},` +
+				`"action":{"type":0,"permission":{"type":2},"data":"https://example.com"}}]}]}}`,
+		},
+		{
+			name: "message with markdown and keyboard",
+			msg: &MessageToCreate{
+				Markdown: NewMarkdown("hi"),
+				Keyboard: &MessageKeyboard{ID: "102_1"},
+				MsgID:    "m1",
+			},
+			want: `{"markdown":{"content":"hi"},"keyboard":{"id":"102_1"},"msg_id":"m1"}`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.msg)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s:\n got  %s\n want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	if got, want := EscapeMarkdown("*小明*_[1]"), `\*小明\*\_\[1\]`; got != want {
+		t.Errorf("EscapeMarkdown = %s, want %s", got, want)
+	}
+}
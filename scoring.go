@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ScoreConfig 成语接龙积分规则，未配置的加成不启用
+type ScoreConfig struct {
+	// Base 每答对一个成语的基础分，为空时为 1
+	Base int `yaml:"base"`
+	// StreakEvery 每连续答对几个成语，基础分的倍率加一，为空时不启用连击加成
+	StreakEvery int `yaml:"streak_every"`
+	// StreakMaxMultiplier 连击倍率上限，为空时不限
+	StreakMaxMultiplier int `yaml:"streak_max_multiplier"`
+	// SpeedWithin 在多长时间内答对算速答，如 10s
+	SpeedWithin time.Duration `yaml:"speed_within"`
+	// SpeedBonus 速答加分
+	SpeedBonus int `yaml:"speed_bonus"`
+	// HintPenalty 本回合用过“提示”时扣的分，单个回合最多扣到 0 分
+	HintPenalty int `yaml:"hint_penalty"`
+	// RareMaxCount 词库中以答案首字开头的成语不超过这么多个时算生僻字
+	RareMaxCount int `yaml:"rare_max_count"`
+	// RareBonus 生僻字加分
+	RareBonus int `yaml:"rare_bonus"`
+	// DailyCap 每名玩家每天最多计入的积分，为空时不限
+	DailyCap int `yaml:"daily_cap"`
+}
+
+// base 基础分
+func (c ScoreConfig) base() int {
+	if c.Base > 0 {
+		return c.Base
+	}
+	return 1
+}
+
+// multiplier 已连续答对 streak 个之后，下一个答案的倍率
+func (c ScoreConfig) multiplier(streak int) int {
+	if c.StreakEvery <= 0 {
+		return 1
+	}
+	m := 1 + streak/c.StreakEvery
+	if c.StreakMaxMultiplier > 0 && m > c.StreakMaxMultiplier {
+		m = c.StreakMaxMultiplier
+	}
+	return m
+}
+
+// ScoreBreakdown 积分明细
+type ScoreBreakdown struct {
+	Answers int `json:"answers"` // 答对的成语数
+	Base    int `json:"base"`    // 基础分
+	Streak  int `json:"streak"`  // 连击加成
+	Speed   int `json:"speed"`   // 速答加成
+	Rare    int `json:"rare"`    // 生僻字加成
+	Hint    int `json:"hint"`    // 提示扣分，为正数
+	Capped  int `json:"capped"`  // 超出每日上限未计入的积分
+}
+
+// Total 计入积分榜的总分
+func (b ScoreBreakdown) Total() int {
+	return b.Base + b.Streak + b.Speed + b.Rare - b.Hint - b.Capped
+}
+
+// Add 累加一次答对的得分
+func (b *ScoreBreakdown) Add(turn ScoreBreakdown) {
+	b.Answers += turn.Answers
+	b.Base += turn.Base
+	b.Streak += turn.Streak
+	b.Speed += turn.Speed
+	b.Rare += turn.Rare
+	b.Hint += turn.Hint
+	b.Capped += turn.Capped
+}
+
+// String 积分明细的文字说明，只列出不为零的项
+func (b ScoreBreakdown) String() string {
+	parts := []string{fmt.Sprintf("答对%d个，基础分%d", b.Answers, b.Base)}
+	if b.Streak > 0 {
+		parts = append(parts, fmt.Sprintf("连击加成+%d", b.Streak))
+	}
+	if b.Speed > 0 {
+		parts = append(parts, fmt.Sprintf("速答加成+%d", b.Speed))
+	}
+	if b.Rare > 0 {
+		parts = append(parts, fmt.Sprintf("生僻字加成+%d", b.Rare))
+	}
+	if b.Hint > 0 {
+		parts = append(parts, fmt.Sprintf("提示扣分-%d", b.Hint))
+	}
+	if b.Capped > 0 {
+		parts = append(parts, fmt.Sprintf("超出每日上限未计入%d", b.Capped))
+	}
+	return fmt.Sprintf("得分明细：%s，共计%d分。", strings.Join(parts, "，"), b.Total())
+}
+
+// scoreTurn 计算一次答对的得分
+// streak 为此前已连续答对的个数，elapsed 为本回合用时，hinted 表示本回合用过“提示”
+func scoreTurn(c ScoreConfig, idiom *Idiom, streak int, elapsed time.Duration, hinted bool) ScoreBreakdown {
+	turn := ScoreBreakdown{Answers: 1, Base: c.base()}
+	turn.Streak = turn.Base * (c.multiplier(streak) - 1)
+	if c.SpeedWithin > 0 && elapsed <= c.SpeedWithin {
+		turn.Speed = c.SpeedBonus
+	}
+	if c.RareMaxCount > 0 && len(idiomDict.StartingWith(idiom.First())) <= c.RareMaxCount {
+		turn.Rare = c.RareBonus
+	}
+	if hinted {
+		turn.Hint = c.HintPenalty
+		if gained := turn.Base + turn.Streak + turn.Speed + turn.Rare; turn.Hint > gained {
+			turn.Hint = gained
+		}
+	}
+	return turn
+}
+
+// settleScore 按每日上限把一局的得分记入积分流水，返回计入后的明细，每日上限在各频道分别计算
+func (p Processor) settleScore(ctx context.Context, record ScoreRecord, b ScoreBreakdown) ScoreBreakdown {
+	if b.Total() <= 0 {
+		return b
+	}
+	record.Score = b.Total()
+	recorded, err := p.store.RecordCappedScore(ctx, record, startOfDay(record.CreatedAt), conf.Game.Scoring.DailyCap)
+	if err != nil {
+		log.Println(err)
+		return b
+	}
+	b.Capped += b.Total() - recorded
+	return b
+}
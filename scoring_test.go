@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// withDailyCap 在测试期间设置每日积分上限
+func withDailyCap(t *testing.T, limit int) {
+	t.Helper()
+	old := conf.Game.Scoring.DailyCap
+	conf.Game.Scoring.DailyCap = limit
+	t.Cleanup(func() { conf.Game.Scoring.DailyCap = old })
+}
+
+func TestSettleScoreConcurrentCap(t *testing.T) {
+	withDailyCap(t, 10)
+	p := Processor{store: newTestStore(t)}
+	ctx := context.Background()
+	record := ScoreRecord{UserID: "u1", GuildID: "g1", ChannelID: "c1", Name: "小明", CreatedAt: time.Now()}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.settleScore(ctx, record, ScoreBreakdown{Base: 4})
+		}()
+	}
+	wg.Wait()
+
+	today, err := p.store.QueryUserScore(ctx, "u1", "g1", startOfDay(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if today != 10 {
+		t.Errorf("today's score = %d, want the daily cap 10", today)
+	}
+}
+
+func TestEndIdiomGameSavesCappedBreakdown(t *testing.T) {
+	withDailyCap(t, 3)
+	store := newTestStore(t)
+	for name, games := range map[string]GameStore{"memory": NewMemoryGameStore(), "database": store.GameStore()} {
+		t.Run(name, func(t *testing.T) {
+			p := Processor{store: store, games: games, timers: newGameTimers()}
+			data := &Message{GuildID: name, ChannelID: "c1", Author: &User{ID: "u1", Username: "小明"}}
+			key := gameKeyOf(data)
+
+			user := &IdiomUser{lastMsg: data}
+			user.breakdown = ScoreBreakdown{Base: 5}
+			user.score = 5
+			if err := p.games.Create(key, user); err != nil {
+				t.Fatal(err)
+			}
+			if summary := p.endIdiomGame(key, nil); summary == "" {
+				t.Fatal("game was not ended")
+			}
+
+			last, err := p.games.Last(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if last.breakdown.Capped != 2 || last.breakdown.Total() != 3 || last.score != 3 {
+				t.Errorf("last game breakdown = %+v, score %d, want 2 capped and 3 recorded", last.breakdown, last.score)
+			}
+			if record := p.chainRecord(data); !strings.Contains(record, last.breakdown.String()) {
+				t.Errorf("chain record does not show the capped breakdown: %s", record)
+			}
+		})
+	}
+}
+
+func TestEndIdiomGameFinishFails(t *testing.T) {
+	store := newTestStore(t)
+	p := Processor{store: store, games: store.GameStore(), timers: newGameTimers()}
+	data := &Message{GuildID: "g1", ChannelID: "c1", Author: &User{ID: "u1", Username: "小明"}}
+	key := gameKeyOf(data)
+	user := &IdiomUser{lastMsg: data, breakdown: ScoreBreakdown{Base: 5}, score: 5}
+	if err := p.games.Create(key, user); err != nil {
+		t.Fatal(err)
+	}
+
+	// 删除进行中的记录失败时，保存的上一局一并回滚，游戏仍在进行且不计分
+	db := store.(*Store).db
+	if _, err := db.Exec(`CREATE TRIGGER fail_finish BEFORE DELETE ON idiom_games BEGIN SELECT RAISE(ABORT, 'boom'); END;`); err != nil {
+		t.Fatal(err)
+	}
+	if summary := p.endIdiomGame(key, nil); summary != "" {
+		t.Fatalf("failed finish returned a summary: %s", summary)
+	}
+	if _, err := p.games.Get(key); err != nil {
+		t.Fatalf("game should still be running: %v", err)
+	}
+	if _, err := p.games.Last(key); !errors.Is(err, ErrNoGame) {
+		t.Errorf("failed finish saved the last game: %v", err)
+	}
+
+	// 再次结束时只计一次分
+	if _, err := db.Exec("DROP TRIGGER fail_finish"); err != nil {
+		t.Fatal(err)
+	}
+	if summary := p.endIdiomGame(key, nil); summary == "" {
+		t.Fatal("game was not ended")
+	}
+	score, err := store.QueryUserScore(context.Background(), "u1", "g1", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score != 5 {
+		t.Errorf("recorded score = %d, want 5", score)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Storage 机器人的持久化数据：积分流水、赛季、玩家资料和进行中的游戏
+// 默认保存在 SQLite 中，配置 PostgreSQL 的 DSN 后改为保存在 PostgreSQL 中，实现需要保证并发安全。
+// 玩家的设置保存在玩家资料的偏好设置中，频道级的设置在配置文件中；网关会话只在一次连接内有效，不做持久化
+type Storage interface {
+	// RecordScore 记一条积分流水
+	RecordScore(ctx context.Context, r ScoreRecord) error
+	// RecordCappedScore 在一个事务中查询玩家自 since 起在频道内的总积分，按上限 limit 记入积分流水，
+	// 返回实际计入的积分，limit 不大于 0 时不限
+	RecordCappedScore(ctx context.Context, r ScoreRecord, since time.Time, limit int) (int, error)
+	// QueryUserScore 玩家自 since 起在频道内的总积分
+	QueryUserScore(ctx context.Context, userID, guildID string, since time.Time) (int, error)
+	// QueryLeaderboard 统计范围内 [since, until) 的积分榜，until 为零值时不限
+	QueryLeaderboard(ctx context.Context, scope BoardScope, since, until time.Time) ([]LeaderboardEntry, error)
+	// QueryPersonalBest 玩家在频道内的个人最佳
+	QueryPersonalBest(ctx context.Context, userID, guildID string) (PersonalBest, error)
+	// QuerySeasons 频道的全部赛季
+	QuerySeasons(ctx context.Context, guildID string) ([]Season, error)
+	// StartSeason 为频道开启新赛季
+	StartSeason(ctx context.Context, guildID, name string, startedAt time.Time) (Season, error)
+	// GuildOfChannel 子频道所属的频道，没有记录时返回空
+	GuildOfChannel(ctx context.Context, channelID string) (string, error)
+
+	// SeePlayer 记录玩家的一次互动，第一次出现时建立资料，返回连续活跃的天数
+	SeePlayer(ctx context.Context, userID, nickname, avatar string, at time.Time) (int, error)
+	// QueryPlayer 玩家资料，没有资料时返回 ErrNoPlayer
+	QueryPlayer(ctx context.Context, userID string) (Player, error)
+	// FindPlayers 按名字查找玩家
+	FindPlayers(ctx context.Context, nickname string, limit int) ([]Player, error)
+	// RecordGame 玩家结束一局游戏
+	RecordGame(ctx context.Context, userID string, streak int) error
+	// SavePreferences 保存玩家的偏好设置，没有资料时返回 ErrNoPlayer
+	SavePreferences(ctx context.Context, userID string, prefs PlayerPrefs) error
+	// UnlockAchievement 为玩家解锁成就，返回是否为第一次解锁
+	UnlockAchievement(ctx context.Context, userID, achievementID string, at time.Time) (bool, error)
+	// QueryAchievements 玩家已解锁的成就，按解锁时间排列
+	QueryAchievements(ctx context.Context, userID string) ([]UnlockedAchievement, error)
+
+	// ClaimLegacyScores 把早期版本迁移来的没有频道的积分归入 guildID 频道，返回归入的条数
+	ClaimLegacyScores(ctx context.Context, guildID string) (int, error)
+	// ListScores 按时间顺序列出积分流水，guildID 为空时列出全部频道的
+	ListScores(ctx context.Context, guildID string) ([]ScoreRecord, error)
+	// ListPlayers 列出全部玩家资料
+	ListPlayers(ctx context.Context) ([]Player, error)
+	// Import 在一个事务中合并导入积分流水与玩家资料，dryRun 时只统计结果不保存
+	Import(ctx context.Context, scores []ScoreRecord, players []Player, dryRun bool) (ImportResult, error)
+
+	// GameStore 保存在数据库中的游戏状态，重启后进行中的游戏可以继续
+	GameStore() GameStore
+	// RelayStore 保存在数据库中的多人接龙，重启后进行中的多人接龙可以继续
+	RelayStore() RelayStore
+
+	// MigrationStatus 全部迁移及其执行情况
+	MigrationStatus(ctx context.Context) ([]MigrationStatus, error)
+	// MigrateUp 执行未执行的迁移直到 target 版本，target 为 0 时执行全部
+	MigrateUp(ctx context.Context, target int) ([]Migration, error)
+	// MigrateDown 回滚最近执行的 steps 个迁移
+	MigrateDown(ctx context.Context, steps int) ([]Migration, error)
+	// Snapshot 把数据库的一致性快照写到 file，不支持时返回 ErrSnapshotUnsupported
+	Snapshot(ctx context.Context, file string) error
+
+	// Close 关闭数据库
+	Close() error
+}
+
+// OpenStorage 按 DSN 打开数据库：postgres:// 或 postgresql:// 开头的为 PostgreSQL，
+// 其余的作为 SQLite 文件路径（可以带 sqlite:// 前缀），为空时使用 ./userscores.db
+func OpenStorage(ctx context.Context, dsn string) (Storage, error) {
+	if isPostgresDSN(dsn) {
+		return OpenStore(ctx, postgresDialect, dsn)
+	}
+	// 使用 WAL 模式，读写可以并发进行；遇到锁时最多等待 5 秒，不会直接报错；
+	// 事务开始时就取得写锁，先查询再写入的事务不会在升级写锁时失败
+	return openSQLite(ctx, sqliteFile(dsn), "?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate")
+}
+
+// OpenStorageReadOnly 以只读方式打开数据库，用于只校验不保存的操作，写入会报错
+func OpenStorageReadOnly(ctx context.Context, dsn string) (Storage, error) {
+	if isPostgresDSN(dsn) {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return OpenStore(ctx, postgresDialect, dsn+sep+"default_transaction_read_only=on")
+	}
+	return openSQLite(ctx, sqliteFile(dsn), "?mode=ro&_busy_timeout=5000")
+}
+
+// openSQLite 打开 SQLite 数据库，并在关闭前一直持有数据库锁文件的共享锁，恢复备份时据此判断数据库是否有进程在用
+func openSQLite(ctx context.Context, file, params string) (*Store, error) {
+	lock, err := lockFile(file, syscall.LOCK_SH)
+	if err != nil {
+		return nil, fmt.Errorf("database %s is being restored: %w", file, err)
+	}
+	s, err := OpenStore(ctx, sqliteDialect, "file:"+file+params)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	s.lock = lock
+	return s, nil
+}
+
+// lockFile 对 SQLite 数据库旁的 .lock 文件加 flock 锁，锁被占用时立即失败而不是等待，
+// 关闭返回的文件即释放锁。打开数据库的进程持有共享锁，恢复备份需要排他锁
+func lockFile(database string, how int) (*os.File, error) {
+	f, err := os.OpenFile(database+".lock", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// isPostgresDSN DSN 是否指向 PostgreSQL
+func isPostgresDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// sqliteFile SQLite DSN 对应的数据库文件路径
+func sqliteFile(dsn string) string {
+	file := strings.TrimPrefix(dsn, "sqlite://")
+	if file == "" {
+		return defaultDatabase
+	}
+	return file
+}
+
+// dialect 不同数据库在 SQL 写法上的差异，查询统一用 ? 作占位符，执行前按方言改写
+type dialect struct {
+	name   string // 同时也是迁移脚本所在的目录名
+	driver string
+	// numbered 占位符是否写作 $1、$2
+	numbered bool
+	// tableExists 查询某张表是否存在的 SQL，参数为表名
+	tableExists string
+	// dayOf 把保存 Unix 时间戳的列换算成日期（如 2023-10-01）的表达式
+	dayOf func(column string) string
+	// lockKey 在事务内按字符串加锁直到事务结束的 SQL，为空时表示事务本身已经串行执行
+	lockKey string
+}
+
+var sqliteDialect = dialect{
+	name:        "sqlite",
+	driver:      "sqlite3",
+	tableExists: "SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)",
+	dayOf: func(column string) string {
+		return "date(" + column + ", 'unixepoch', 'localtime')"
+	},
+}
+
+// postgresDialect 日期按数据库会话的时区换算，需要与机器人所在时区一致
+var postgresDialect = dialect{
+	name:        "postgres",
+	driver:      "postgres",
+	numbered:    true,
+	tableExists: "SELECT to_regclass(?) IS NOT NULL",
+	lockKey:     "SELECT pg_advisory_xact_lock(hashtext(?))",
+	dayOf: func(column string) string {
+		return "to_char(to_timestamp(" + column + "), 'YYYY-MM-DD')"
+	},
+}
+
+// rebind 把查询中的 ? 与 ?1 改写成方言的占位符
+func (d dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] != '?' {
+			b.WriteByte(query[i])
+			continue
+		}
+		j := i + 1
+		for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+			j++
+		}
+		b.WriteByte('$')
+		if j > i+1 {
+			b.WriteString(query[i+1 : j])
+		} else {
+			n++
+			b.WriteString(strconv.Itoa(n))
+		}
+		i = j - 1
+	}
+	return b.String()
+}